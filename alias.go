@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	latestAlias = "latest"
+	nodeAlias   = "node"
+	ltsPrefix   = "lts/"
+)
+
+func isAlias(str string) bool {
+	str = strings.ToLower(str)
+	return str == latestAlias || str == nodeAlias || strings.HasPrefix(str, ltsPrefix)
+}
+
+// resolveAlias sets the range of the alias spec from the release index.
+// "latest" and "node" are the newest release or later, "lts/<codename>" is the LTS line of the codename,
+// "lts/*" is the newest LTS line and "lts/-N" is the Nth LTS line before the newest.
+func resolveAlias(ctx context.Context, spec *versionSpec) error {
+	if spec.alias == "" || spec.rng != nil {
+		return nil
+	}
+	releases, err := fetchReleaseIndex(ctx, releaseChannel)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", spec.alias, err)
+	}
+
+	var (
+		newest Version
+		// first and last version of each LTS codename.
+		ltsFirst = map[string]Version{}
+		ltsLast  = map[string]Version{}
+	)
+	for _, release := range releases {
+		v := release.Version
+		if v.Compare(newest) > 0 {
+			newest = v
+		}
+		if release.LTS == "" {
+			continue
+		}
+		codename := strings.ToLower(string(release.LTS))
+		if first, ok := ltsFirst[codename]; !ok || v.Compare(first) < 0 {
+			ltsFirst[codename] = v
+		}
+		if last, ok := ltsLast[codename]; !ok || v.Compare(last) > 0 {
+			ltsLast[codename] = v
+		}
+	}
+
+	alias := strings.ToLower(spec.alias)
+	if alias == latestAlias || alias == nodeAlias {
+		spec.rng = versionRange{{{op: ">=", version: newest}}}
+		debugf(ctx, "%s is resolved to %s", spec.alias, spec.rng)
+		return nil
+	}
+
+	codenames := make([]string, 0, len(ltsLast))
+	for codename := range ltsLast {
+		codenames = append(codenames, codename)
+	}
+	slices.SortFunc(codenames, func(l, r string) int {
+		return ltsLast[r].Compare(ltsLast[l])
+	})
+
+	codename := strings.TrimPrefix(alias, ltsPrefix)
+	switch {
+	case codename == "*":
+		if len(codenames) == 0 {
+			return fmt.Errorf("no LTS release is found")
+		}
+		codename = codenames[0]
+	case strings.HasPrefix(codename, "-"):
+		n, err := strconv.Atoi(codename[1:])
+		if err != nil {
+			return fmt.Errorf("%s is not support format: %w", spec.alias, err)
+		}
+		if n >= len(codenames) {
+			return fmt.Errorf("%s is not found", spec.alias)
+		}
+		codename = codenames[n]
+	}
+	first, ok := ltsFirst[codename]
+	if !ok {
+		return fmt.Errorf("%s is not found", spec.alias)
+	}
+	spec.rng = versionRange{{
+		{op: ">=", version: first},
+		{op: "<", version: Version{Major: first.Major + 1, Prerelease: "0"}},
+	}}
+	debugf(ctx, "%s is resolved to %s", spec.alias, spec.rng)
+	return nil
+}
+
+// resolveVersionString parses the version string and resolves its alias.
+func resolveVersionString(ctx context.Context, str string) (*versionSpec, error) {
+	spec, err := parseVersionString(str)
+	if err != nil {
+		return nil, err
+	}
+	if err := resolveAlias(ctx, spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+const cacheDir = "cache"
+
+var CacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage downloaded tarballs",
+}
+
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List cached tarballs",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		if err := outputCache(cmd.Context()); err != nil {
+			fatal(cmd.Context(), err)
+		}
+	},
+}
+
+var cacheCleanCmd = &cobra.Command{
+	Use:   "clean [version...]",
+	Short: "Remove cached tarballs. All tarballs are removed without version",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := CleanCache(cmd.Context(), args); err != nil {
+			fatal(cmd.Context(), err)
+		}
+	},
+}
+
+var cacheVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify checksums of cached tarballs and remove broken ones",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		if err := VerifyCache(cmd.Context()); err != nil {
+			fatal(cmd.Context(), err)
+		}
+	},
+}
+
+func init() {
+	CacheCmd.AddCommand(cacheListCmd, cacheCleanCmd, cacheVerifyCmd)
+}
+
+// cachePath returns the path of the tarball in the cache.
+// The cache is $HOME/.nvs/cache/<version>/<platform>/<sha256><ext>.
+func cachePath(baseDir string, v Version, platform, sha256, ext string) string {
+	return filepath.Join(baseDir, cacheDir, v.String(), platform, sha256+ext)
+}
+
+// readCache returns all tarballs in the cache sorted by version in descending order.
+func readCache(baseDir string) ([]*tarball, error) {
+	versions, err := os.ReadDir(filepath.Join(baseDir, cacheDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var tarballs []*tarball
+	for _, version := range versions {
+		v, err := parseVersion(version.Name())
+		if err != nil || !version.IsDir() {
+			continue
+		}
+		platforms, err := os.ReadDir(filepath.Join(baseDir, cacheDir, version.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, platform := range platforms {
+			files, err := os.ReadDir(filepath.Join(baseDir, cacheDir, version.Name(), platform.Name()))
+			if err != nil {
+				return nil, err
+			}
+			for _, file := range files {
+				sha256, ext, ok := strings.Cut(file.Name(), ".")
+				if !ok || !slices.Contains(archiveExts, "."+ext) {
+					continue
+				}
+				tarballs = append(tarballs, &tarball{
+					version:  v,
+					platform: platform.Name(),
+					ext:      "." + ext,
+					sha256:   sha256,
+					path:     filepath.Join(baseDir, cacheDir, version.Name(), platform.Name(), file.Name()),
+				})
+			}
+		}
+	}
+	slices.SortStableFunc(tarballs, func(l, r *tarball) int {
+		return r.version.Compare(l.version)
+	})
+	return tarballs, nil
+}
+
+var ErrNotFoundCache = fmt.Errorf("not found cached tarball")
+
+func findCachedTarball(baseDir string, v *versionSpec, platform string) (*tarball, error) {
+	tarballs, err := readCache(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tarballs {
+		if t.platform == platform && v.match(t.version) {
+			return t, nil
+		}
+	}
+	return nil, ErrNotFoundCache
+}
+
+func outputCache(_ context.Context) error {
+	baseDir, err := checkInit()
+	if err != nil {
+		return err
+	}
+	tarballs, err := readCache(baseDir)
+	if err != nil {
+		return err
+	}
+	var buf strings.Builder
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for _, t := range tarballs {
+		var size int64
+		if fi, err := os.Stat(t.path); err == nil {
+			size = fi.Size()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.1fMiB\n", t.version, t.platform, t.sha256, float64(size)/(1<<20))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	os.Stdout.WriteString(buf.String())
+	return nil
+}
+
+func CleanCache(ctx context.Context, versions []string) error {
+	baseDir, err := checkInit()
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		infof(ctx, "remove %s", filepath.Join(baseDir, cacheDir))
+		return os.RemoveAll(filepath.Join(baseDir, cacheDir))
+	}
+
+	specs := make([]*versionSpec, 0, len(versions))
+	for _, version := range versions {
+		spec, err := resolveVersionString(ctx, version)
+		if err != nil {
+			return err
+		}
+		specs = append(specs, spec)
+	}
+	tarballs, err := readCache(baseDir)
+	if err != nil {
+		return err
+	}
+	for _, t := range tarballs {
+		if !slices.ContainsFunc(specs, func(spec *versionSpec) bool { return spec.match(t.version) }) {
+			continue
+		}
+		dir := filepath.Join(baseDir, cacheDir, t.version.String())
+		infof(ctx, "remove %s", dir)
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func VerifyCache(ctx context.Context) error {
+	baseDir, err := checkInit()
+	if err != nil {
+		return err
+	}
+	tarballs, err := readCache(baseDir)
+	if err != nil {
+		return err
+	}
+	var errs error
+	for _, t := range tarballs {
+		if err := verifyChecksum(ctx, t.path, t.sha256); err != nil {
+			warnf(ctx, "remove %s: %v", t.path, err)
+			errs = errors.Join(errs, err)
+			if err := os.Remove(t.path); err != nil {
+				return err
+			}
+			continue
+		}
+		infof(ctx, "%s %s is ok", t.version, t.platform)
+	}
+	return errs
+}
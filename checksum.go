@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const shasumsFile = "SHASUMS256.txt"
+
+func fetchReleaseFile(ctx context.Context, channel, versionDir, name string) ([]byte, error) {
+	u, err := url.JoinPath(channelURLs[channel], versionDir, name)
+	if err != nil {
+		return nil, err
+	}
+	return fetchURL(ctx, u)
+}
+
+// fetchChecksums fetches SHASUMS256.txt and verifies its signature.
+func fetchChecksums(ctx context.Context, baseDir, channel, versionDir string) (map[string]string, error) {
+	shasums, err := fetchReleaseFile(ctx, channel, versionDir, shasumsFile)
+	if err != nil {
+		return nil, err
+	}
+	shasums, err = verifyChecksumsSignature(ctx, baseDir, channel, versionDir, shasums)
+	if err != nil {
+		return nil, err
+	}
+	return parseChecksums(bytes.NewReader(shasums))
+}
+
+// parseChecksums parses the output of sha256sum. The key is the file name.
+func parseChecksums(r io.Reader) (map[string]string, error) {
+	checksums := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		checksums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", shasumsFile, err)
+	}
+	return checksums, nil
+}
+
+func fileSHA256(ctx context.Context, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	p := startProgress(ctx, "verify", fi.Size())
+	defer p.finish()
+	h := sha256.New()
+	if _, err := io.Copy(h, p.reader(f)); err != nil {
+		return "", fmt.Errorf("hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+var ErrChecksumMismatch = fmt.Errorf("checksum mismatch")
+
+func verifyChecksum(ctx context.Context, path, want string) error {
+	got, err := fileSHA256(ctx, path)
+	if err != nil {
+		return err
+	}
+	if got != strings.ToLower(want) {
+		return fmt.Errorf("%w: %s expected sha256 %s, but got %s", ErrChecksumMismatch, filepath.Base(path), want, got)
+	}
+	return nil
+}
+
+const installReceiptFile = ".nvs-receipt.json"
+
+type installReceipt struct {
+	Version string `json:"version"`
+	File    string `json:"file"`
+	URL     string `json:"url"`
+	SHA256  string `json:"sha256"`
+	// Platform is the platform of the tarball such as "linux-x64".
+	Platform    string    `json:"platform,omitempty"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+func readInstallReceipt(dir string) (*installReceipt, error) {
+	b, err := os.ReadFile(filepath.Join(dir, installReceiptFile))
+	if err != nil {
+		return nil, err
+	}
+	var receipt installReceipt
+	if err := json.Unmarshal(b, &receipt); err != nil {
+		return nil, err
+	}
+	return &receipt, nil
+}
+
+// forHost reports whether the installed version runs on this machine.
+// Versions installed without the platform in the receipt are assumed to be.
+func (r *installReceipt) forHost() bool {
+	if r.Platform == "" {
+		return true
+	}
+	for _, p := range hostPlatform().candidates() {
+		if r.Platform == p.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// isHostInstall reports whether the version installed in dir runs on this machine.
+func isHostInstall(dir string) bool {
+	receipt, err := readInstallReceipt(dir)
+	return err != nil || receipt.forHost()
+}
+
+func writeInstallReceipt(dir string, receipt installReceipt) error {
+	b, err := json.MarshalIndent(receipt, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, installReceiptFile), append(b, '\n'), 0o644)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const configFile = "config.json"
+
+var mirrorEnvs = []string{"NVS_NODEJS_ORG_MIRROR", "NVM_NODEJS_ORG_MIRROR"}
+
+const (
+	offlineEnv  = "NVS_OFFLINE"
+	indexTTLEnv = "NVS_INDEX_TTL"
+)
+
+// indexTTL is the duration to use the cached release index without requests.
+var indexTTL = time.Hour
+
+// offline disables network access. Versions are resolved with installed versions and the cache.
+var offline bool
+
+var ErrOffline = fmt.Errorf("offline mode")
+
+type config struct {
+	// Mirror is the base URL of the release channel.
+	Mirror string `json:"mirror"`
+	// Channels overrides the base URL of each channel.
+	Channels map[string]string `json:"channels"`
+	// Headers are sent to the hosts of Mirror, Channels and the mirror environment variables.
+	Headers  map[string]string `json:"headers"`
+	Username string            `json:"username"`
+	Password string            `json:"password"`
+	// Offline is the default of --offline.
+	Offline bool `json:"offline"`
+	// IndexTTL is the duration such as "1h" to use the cached release index without requests.
+	IndexTTL string `json:"index_ttl"`
+}
+
+var nvsConfig config
+
+// configuredHosts are the hosts configured by the user. Only they receive the headers and credentials of the config,
+// so that they do not leak to the default hosts such as nodejs.org.
+var configuredHosts = make(map[string]bool)
+
+// loadConfig reads $HOME/.nvs/config.json and mirror environment variables, and applies them to channelURLs.
+func loadConfig(ctx context.Context) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(home, nvsDir, configFile)
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, &nvsConfig); err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+
+	if env := os.Getenv(offlineEnv); env != "" {
+		b, err := strconv.ParseBool(env)
+		if err != nil {
+			return fmt.Errorf("%s: %w", offlineEnv, err)
+		}
+		offline = offline || b
+	}
+	offline = offline || nvsConfig.Offline
+	if offline {
+		debugf(ctx, "offline mode")
+	}
+
+	ttl := nvsConfig.IndexTTL
+	if env := os.Getenv(indexTTLEnv); env != "" {
+		ttl = env
+	}
+	if ttl != "" {
+		indexTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			return fmt.Errorf("index TTL: %w", err)
+		}
+	}
+
+	configured := make(map[string]bool)
+	for channel, u := range nvsConfig.Channels {
+		if _, ok := channelURLs[channel]; !ok {
+			return fmt.Errorf("%s: unknown channel %s", path, channel)
+		}
+		channelURLs[channel] = u
+		configured[channel] = true
+	}
+	if nvsConfig.Mirror != "" {
+		channelURLs[releaseChannel] = nvsConfig.Mirror
+		configured[releaseChannel] = true
+	}
+	for _, env := range mirrorEnvs {
+		if mirror := os.Getenv(env); mirror != "" {
+			debugf(ctx, "use %s", env)
+			channelURLs[releaseChannel] = mirror
+			configured[releaseChannel] = true
+			break
+		}
+	}
+	for channel, u := range channelURLs {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return fmt.Errorf("%s channel URL: %w", channel, err)
+		}
+		if !strings.HasSuffix(u, "/") {
+			channelURLs[channel] = u + "/"
+		}
+		if configured[channel] {
+			configuredHosts[parsed.Host] = true
+		}
+	}
+	return nil
+}
+
+// newRequest creates a request with the headers of the config and the credentials of the config or netrc.
+// The config headers and credentials are only sent to configuredHosts.
+func newRequest(ctx context.Context, method, u string) (*http.Request, error) {
+	if offline {
+		return nil, fmt.Errorf("%w: cannot access %s", ErrOffline, u)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if configuredHosts[req.URL.Host] {
+		for k, v := range nvsConfig.Headers {
+			req.Header.Set(k, v)
+		}
+		if nvsConfig.Username != "" {
+			req.SetBasicAuth(nvsConfig.Username, nvsConfig.Password)
+			return req, nil
+		}
+	}
+	if req.Header.Get("Authorization") == "" {
+		if login, password, ok := netrcCredentials(req.URL.Hostname()); ok {
+			req.SetBasicAuth(login, password)
+		}
+	}
+	return req, nil
+}
+
+// netrcCredentials returns the login and password of the machine in $NETRC or $HOME/.netrc.
+func netrcCredentials(host string) (login, password string, ok bool) {
+	path := os.Getenv("NETRC")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", false
+		}
+		path = filepath.Join(home, ".netrc")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", false
+	}
+	defer f.Close()
+
+	var (
+		tokens  []string
+		scanner = bufio.NewScanner(f)
+	)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, strings.Fields(line)...)
+	}
+
+	var machine, defaultLogin, defaultPassword string
+	var inDefault bool
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "machine":
+			if i+1 < len(tokens) {
+				i++
+				machine, inDefault = tokens[i], false
+			}
+		case "default":
+			machine, inDefault = "", true
+		case "login", "password":
+			if i+1 >= len(tokens) {
+				break
+			}
+			key, value := tokens[i], tokens[i+1]
+			i++
+			switch {
+			case inDefault && key == "login":
+				defaultLogin = value
+			case inDefault:
+				defaultPassword = value
+			case machine == host && key == "login":
+				login = value
+			case machine == host:
+				password = value
+			}
+		}
+	}
+	if login != "" || password != "" {
+		return login, password, true
+	}
+	if defaultLogin != "" || defaultPassword != "" {
+		return defaultLogin, defaultPassword, true
+	}
+	return "", "", false
+}
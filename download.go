@@ -1,10 +1,8 @@
 package main
 
 import (
-	"archive/tar"
-	"bytes"
-	"compress/gzip"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -13,267 +11,671 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
-	"slices"
 	"strings"
 	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
-	"golang.org/x/net/html"
+)
+
+var (
+	downloadChannelArg string
+	downloadOSArg      string
+	downloadArchArg    string
+	downloadLibcArg    string
+	downloadFileArg    string
+	downloadDirArg     string
+	downloadSHA256Arg  string
+	downloadKeepGoing  bool
 )
 
 var DownloadCmd = &cobra.Command{
-	Use:   "download [version]",
+	Use:   "download [version...]",
 	Short: "Download specify version of Nodejs",
-	Args:  cobra.MinimumNArgs(1),
+	Args:  cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := cmd.Context()
-		for _, arg := range args {
-			v, err := parseVersionString(arg)
-			if err != nil {
-				fatal(ctx, err)
-			}
-			if err := Download(ctx, v); err != nil {
+		p, err := parsePlatform(downloadOSArg, downloadArchArg, downloadLibcArg)
+		if err != nil {
+			fatal(ctx, err)
+		}
+		if p != hostPlatform() {
+			infof(ctx, "%s is not the platform of this machine(%s). it is installed in $HOME/.nvs/%s/%s", p, hostPlatform(), platformsDir, p)
+		}
+		if downloadFileArg != "" || downloadDirArg != "" {
+			if err := downloadLocal(ctx, args, p); err != nil {
 				fatal(ctx, err)
 			}
+			return
+		}
+		if len(args) == 0 || downloadSHA256Arg != "" {
+			cmd.Usage()
+			os.Exit(1)
+		}
+		if err := downloadVersions(ctx, args, p); err != nil {
+			fatal(ctx, err)
 		}
 	},
 }
 
-var maxWorkers = runtime.NumCPU() * 4
-
-type downloadPath struct {
-	path     string
-	name     string
-	priority int
+func init() {
+	DownloadCmd.Flags().StringVar(&downloadChannelArg, "channel", "", "download channel(release, rc, nightly, v8-canary or test)")
+	DownloadCmd.Flags().StringVar(&downloadOSArg, "os", "", "target os such as linux or darwin (default this machine)")
+	DownloadCmd.Flags().StringVar(&downloadArchArg, "arch", "", "target arch such as x64, arm64 or armv7l (default this machine)")
+	DownloadCmd.Flags().StringVar(&downloadLibcArg, "libc", "", "target libc of linux, glibc or musl (default this machine)")
+	DownloadCmd.Flags().StringVar(&downloadFileArg, "from-file", "", "install from the local tarball instead of downloading")
+	DownloadCmd.Flags().StringVar(&downloadDirArg, "from-dir", "", "install from the local extracted Nodejs directory instead of downloading")
+	DownloadCmd.Flags().StringVar(&downloadSHA256Arg, "sha256", "", "sha256 of --from-file")
+	DownloadCmd.Flags().BoolVar(&downloadKeepGoing, "keep-going", false, "continue downloading other versions after a failure")
 }
 
-const nodejsURL = "https://nodejs.org/dist/"
+type downloadResult struct {
+	arg       string
+	installed string
+	err       error
+}
 
-func findTarget(ctx context.Context, v *version) (string, error) {
-	r, err := http.NewRequest(http.MethodGet, nodejsURL, nil)
-	if err != nil {
-		return "", err
-	}
-	resp, err := http.DefaultClient.Do(r)
+// downloadVersions downloads the versions concurrently. They share the connection budget.
+// A failure cancels the other downloads unless --keep-going.
+// The summary is printed when there are multiple versions.
+func downloadVersions(ctx context.Context, args []string, p platform) error {
+	base, err := checkInit()
 	if err != nil {
-		return "", err
+		return err
 	}
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		return "", fmt.Errorf("status is %d. response %s", resp.StatusCode, body)
+	if len(args) > 1 {
+		// multiple bars cannot be drawn on a line, so progress is logged with the version.
+		progressBar = false
 	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	doc, err := html.Parse(resp.Body)
-	if err != nil {
-		return "", err
-	}
-	var f func(*html.Node) error
-	var downloadpaths []downloadPath
-	f = func(n *html.Node) error {
-		if n.Type == html.ElementNode && n.Data == "a" {
-			data := n.FirstChild.Data
-			if !versionRegex.MatchString(data) {
-				return nil
+	results := make([]downloadResult, len(args))
+	var wg sync.WaitGroup
+	for i, arg := range args {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := ctx
+			if len(args) > 1 {
+				ctx = withProgressLabel(ctx, arg)
 			}
-			splitVersion := strings.Split(strings.Trim(data, "v/"), ".")
-			match, err := compareVersionString(splitVersion, v)
-			if err != nil {
-				debugf(ctx, "%s is skipped: %v", data, err)
+			results[i] = downloadResult{arg: arg}
+			v, err := resolveVersionString(ctx, arg)
+			if err == nil {
+				v, err = v.withChannel(downloadChannelArg)
 			}
-			if match {
-				downloadpaths = append(downloadpaths, downloadPath{
-					path:     strings.TrimRight(data, "/"),
-					priority: calcPriority(splitVersion),
-				})
+			if err == nil {
+				err = Download(ctx, v, p)
 			}
-		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			if err := f(c); err != nil {
-				return err
+			if err == nil {
+				results[i].installed, err = findLocalVersion(base, v)
 			}
+			if err != nil {
+				results[i].err = err
+				if !downloadKeepGoing {
+					cancel()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(args) == 1 {
+		return results[0].err
+	}
+	var (
+		buf    strings.Builder
+		failed int
+	)
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for _, r := range results {
+		switch {
+		case r.err == nil:
+			fmt.Fprintf(w, "%s\t%s\tok\n", r.arg, r.installed)
+		case errors.Is(r.err, context.Canceled):
+			failed++
+			fmt.Fprintf(w, "%s\t-\tcanceled\n", r.arg)
+		default:
+			failed++
+			fmt.Fprintf(w, "%s\t-\tfailed: %v\n", r.arg, r.err)
 		}
-		return nil
 	}
-	if err := f(doc); err != nil {
-		return "", err
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	os.Stdout.WriteString(buf.String())
+	if failed > 0 {
+		return fmt.Errorf("%d of %d versions are not downloaded", failed, len(args))
 	}
+	return nil
+}
 
-	if len(downloadpaths) == 0 {
-		return "", fmt.Errorf("no much version")
+func downloadLocal(ctx context.Context, args []string, p platform) error {
+	switch {
+	case len(args) > 0:
+		return fmt.Errorf("version is inferred from --from-file or --from-dir")
+	case downloadFileArg != "" && downloadDirArg != "":
+		return fmt.Errorf("--from-file and --from-dir are exclusive")
+	case downloadDirArg != "" && downloadSHA256Arg != "":
+		return fmt.Errorf("--sha256 is only for --from-file")
+	case downloadDirArg != "":
+		return DownloadDir(ctx, downloadDirArg, p)
 	}
+	return DownloadFile(ctx, downloadFileArg, downloadSHA256Arg, p)
+}
 
-	slices.SortFunc(downloadpaths, func(l, r downloadPath) int {
-		return r.priority - l.priority
-	})
+var maxWorkers = runtime.NumCPU() * 4
+
+// connections is the budget of tarball connections shared by all downloads.
+var connections = make(chan struct{}, maxWorkers)
+
+func acquireConnection(ctx context.Context) (release func(), err error) {
+	select {
+	case connections <- struct{}{}:
+		return func() { <-connections }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
 
-	return downloadpaths[0].path, nil
+const releaseChannel = "release"
+
+// unofficialChannel is the unofficial builds for the platforms which nodejs.org does not publish.
+// It is not a channel of versions, but its URL is configurable as a channel.
+const unofficialChannel = "unofficial"
+
+var channelURLs = map[string]string{
+	releaseChannel:    "https://nodejs.org/dist/",
+	"rc":              "https://nodejs.org/download/rc/",
+	"nightly":         "https://nodejs.org/download/nightly/",
+	"v8-canary":       "https://nodejs.org/download/v8-canary/",
+	"test":            "https://nodejs.org/download/test/",
+	unofficialChannel: "https://unofficial-builds.nodejs.org/download/release/",
+}
+
+// isChannel reports whether name is a channel of versions.
+func isChannel(name string) bool {
+	_, ok := channelURLs[name]
+	return ok && name != unofficialChannel
+}
+
+func versionChannel(v Version) string {
+	for channel := range channelURLs {
+		if isChannel(channel) && channel != releaseChannel && strings.HasPrefix(v.Prerelease, channel) {
+			return channel
+		}
+	}
+	return releaseChannel
+}
+
+func findTarget(ctx context.Context, v *versionSpec) (*release, error) {
+	releases, err := fetchReleaseIndex(ctx, v.channel)
+	if err != nil {
+		return nil, err
+	}
+	for _, release := range releases {
+		if v.match(release.Version) {
+			return &release, nil
+		}
+	}
+	return nil, fmt.Errorf("no much version")
 }
 
-func Download(ctx context.Context, v *version) error {
+type tarball struct {
+	version Version
+	// platform is the platform of the file name such as "linux-x64".
+	platform string
+	// ext is the archive extension such as ".tar.gz".
+	ext    string
+	url    string
+	sha256 string
+	// path is the file in the cache.
+	path string
+}
+
+// name returns the file name without extension. It is also the top directory of the archive.
+func (t *tarball) name() string {
+	return fmt.Sprintf("node-%s-%s", t.version, t.platform)
+}
+
+func Download(ctx context.Context, v *versionSpec, p platform) error {
 	base, err := checkInit()
 	if err != nil {
 		return err
 	}
-	path, err := findTarget(ctx, v)
+	t, err := fetchTarball(ctx, base, v, p)
 	if err != nil {
 		return err
 	}
+	path := t.version.String()
+	target := installPath(base, path, p)
 
-	downloadFile := fmt.Sprintf("node-%s-%s-%s", path, runtime.GOOS, strings.ReplaceAll(runtime.GOARCH, "amd", "x"))
-	var tmpFile *os.File
-	for {
-		u, err := url.JoinPath(nodejsURL, path, downloadFile+".tar.gz")
-		if err != nil {
-			return err
-		}
-		infof(ctx, "download %s", u)
-		tmpFile, err = download(ctx, u)
-		if err != nil {
-			if errors.Is(err, ErrNotFoundFile) && strings.Contains(downloadFile, "arm") {
-				infof(ctx, "%s is not found", u)
-				downloadFile = fmt.Sprintf("node-%s-%s-%s", path, runtime.GOOS, strings.ReplaceAll(runtime.GOARCH, "arm", "x"))
-				continue
-			}
-			return err
-		}
-		break
-	}
-
-	infof(ctx, "extract %s", tmpFile.Name())
-	dir, err := extract(tmpFile)
+	unlock, err := lockInstall(ctx, base, target)
 	if err != nil {
 		return err
 	}
+	defer unlock()
+	// another process may have installed the same tarball while waiting for the lock.
+	if receipt, err := readInstallReceipt(target); err == nil && receipt.SHA256 == t.sha256 && receipt.InstalledAt.After(startedAt) {
+		infof(ctx, "use %s installed by another download", path)
+		return nil
+	}
 
-	fromDir := filepath.Join(dir, downloadFile)
-	infof(ctx, "copy from %s", fromDir)
-	targetPath := filepath.Join(base, "versions", path)
-	if err := os.RemoveAll(targetPath); err != nil {
-		return fmt.Errorf("remove %s: %w", targetPath, err)
+	dir, err := newStagingDir(base)
+	if err != nil {
+		return err
 	}
-	if err := os.Rename(fromDir, targetPath); err != nil {
+	defer os.RemoveAll(dir)
+	if err := extractTarball(ctx, t.path, t.ext, dir); err != nil {
 		return err
 	}
+	return installVersion(ctx, base, dir, filepath.Join(dir, t.name()), target, installReceipt{
+		Version:  path,
+		File:     t.name() + t.ext,
+		URL:      t.url,
+		SHA256:   t.sha256,
+		Platform: t.platform,
+	})
+}
 
-	return nil
+// platformsDir has versions for the other platforms than this machine, such as platforms/linux-arm64/v20.11.0.
+// They are not in versions, so that they are never run on this machine.
+const platformsDir = "platforms"
+
+// installPath returns the directory where the version for p is installed.
+func installPath(base, version string, p platform) string {
+	if p == hostPlatform() {
+		return filepath.Join(base, "versions", version)
+	}
+	return filepath.Join(base, platformsDir, p.String(), version)
 }
 
-func extract(file *os.File) (string, error) {
-	gr, err := gzip.NewReader(file)
+func extractTarball(ctx context.Context, path, ext, dir string) error {
+	f, err := os.Open(path)
 	if err != nil {
-		return "", fmt.Errorf("new gzip reader: %w", err)
+		return err
 	}
-
-	var buf bytes.Buffer
-	if _, err := io.Copy(&buf, gr); err != nil {
-		return "", fmt.Errorf("copy to buffer: %w", err)
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
 	}
+	infof(ctx, "extract %s", path)
+	bar := startProgress(ctx, "extract", fi.Size())
+	defer bar.finish()
+	return extract(bar.reader(f), ext, dir)
+}
 
-	dir := file.Name()[:strings.LastIndex(file.Name(), ".tar.gz")]
-	if err := os.Mkdir(dir, 0o755); err != nil {
-		return "", err
+// installVersion writes the receipt into fromDir and installs it into target.
+// The caller must have the lock of target.
+func installVersion(ctx context.Context, base, staging, fromDir, target string, receipt installReceipt) error {
+	receipt.InstalledAt = time.Now()
+	if err := writeInstallReceipt(fromDir, receipt); err != nil {
+		return err
+	}
+	infof(ctx, "install %s", target)
+	if err := installDir(base, staging, fromDir, target); err != nil {
+		return fmt.Errorf("install %s: %w", target, err)
 	}
+	return nil
+}
 
-	tr := tar.NewReader(&buf)
-	for {
-		hdr, err := tr.Next()
-		if errors.Is(err, io.EOF) {
-			break
+// fetchTarball returns the verified tarball in the cache. It is downloaded if the cache does not have it.
+// When the release index or the server is unavailable, the latest matched tarball in the cache is used.
+// Other errors such as ErrInvalidSignature and ErrChecksumMismatch are returned without the cache.
+func fetchTarball(ctx context.Context, base string, v *versionSpec, p platform) (*tarball, error) {
+	err := ErrOffline
+	if !offline {
+		var t *tarball
+		t, err = fetchRemoteTarball(ctx, base, v, p)
+		if err == nil || errors.Is(err, context.Canceled) || !errors.Is(err, errIndexUnavailable) && !isUnavailable(err) {
+			return t, err
 		}
-		if hdr.Typeflag == tar.TypeDir {
-			dir := filepath.Join(dir, hdr.Name)
-			if _, err = os.Stat(dir); os.IsNotExist(err) {
-				if err := os.Mkdir(dir, hdr.FileInfo().Mode()); err != nil {
-					return "", err
-				}
-			}
+	}
+	for _, c := range p.candidates() {
+		t, cacheErr := findCachedTarball(base, v, c.String())
+		if cacheErr != nil {
 			continue
 		}
-		if hdr.Typeflag == tar.TypeSymlink {
-			if err := os.Symlink(hdr.Linkname, filepath.Join(dir, hdr.Name)); err != nil {
-				return "", err
-			}
+		warnf(ctx, "use cached %s: %v", t.name()+t.ext, err)
+		if err := verifyChecksum(ctx, t.path, t.sha256); err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+	if offline {
+		return nil, fmt.Errorf("%w: %s is not installed and not in the cache", ErrOffline, v)
+	}
+	return nil, err
+}
+
+func fetchRemoteTarball(ctx context.Context, base string, v *versionSpec, p platform) (*tarball, error) {
+	target, err := findTarget(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	path := target.Version.String()
+
+	channel, p, err := findPlatform(ctx, v.channel, target, p)
+	if err != nil {
+		return nil, err
+	}
+	checksums, err := fetchChecksums(ctx, base, channel, path)
+	if err != nil {
+		return nil, err
+	}
+	var tarballs []*tarball
+	for _, ext := range archiveExts {
+		t := &tarball{version: target.Version, platform: p.String(), ext: ext}
+		checksum, ok := checksums[t.name()+ext]
+		if !ok {
+			debugf(ctx, "%s has no checksum of %s", shasumsFile, t.name()+ext)
 			continue
 		}
+		t.sha256 = checksum
+		t.url, err = url.JoinPath(channelURLs[channel], path, t.name()+ext)
+		if err != nil {
+			return nil, err
+		}
+		t.path = cachePath(base, t.version, t.platform, checksum, ext)
+		tarballs = append(tarballs, t)
+	}
+	if len(tarballs) == 0 {
+		return nil, fmt.Errorf("%s has no checksum of node-%s-%s", shasumsFile, path, p)
+	}
 
-		file, err := os.OpenFile(filepath.Join(dir, hdr.Name), os.O_RDWR|os.O_CREATE|os.O_TRUNC, hdr.FileInfo().Mode())
+	for _, t := range tarballs {
+		ok, err := useCachedTarball(ctx, t)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		if _, err := io.Copy(file, tr); err != nil {
-			file.Close()
-			return "", fmt.Errorf("copy to %s: %w", file.Name(), err)
+		if ok {
+			return t, nil
+		}
+	}
+	var errs error
+	for _, t := range tarballs {
+		err := downloadTarball(ctx, t)
+		if errors.Is(err, ErrNotFoundFile) {
+			infof(ctx, "%s is not found", t.url)
+			errs = errors.Join(errs, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
 		}
-		file.Close()
+		return t, nil
 	}
+	return nil, errs
+}
 
-	return dir, err
+// useCachedTarball reports whether the cache has the verified tarball. A broken cache is removed.
+func useCachedTarball(ctx context.Context, t *tarball) (bool, error) {
+	if _, err := os.Stat(t.path); err != nil {
+		return false, nil
+	}
+	infof(ctx, "use cached %s", t.name()+t.ext)
+	err := verifyChecksum(ctx, t.path, t.sha256)
+	if err == nil {
+		return true, nil
+	}
+	warnf(ctx, "remove broken cache: %v", err)
+	if err := os.Remove(t.path); err != nil {
+		return false, err
+	}
+	return false, nil
 }
 
+func downloadTarball(ctx context.Context, t *tarball) error {
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return err
+	}
+	unlock, err := lockFile(ctx, t.path+".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// another process may have downloaded it while waiting for the lock.
+	if ok, err := useCachedTarball(ctx, t); err != nil || ok {
+		return err
+	}
+
+	partPath := t.path + ".part"
+	infof(ctx, "download %s", t.url)
+	if err := download(ctx, t.url, partPath); err != nil {
+		return err
+	}
+	infof(ctx, "verify %s", t.name()+t.ext)
+	if err := verifyChecksum(ctx, partPath, t.sha256); err != nil {
+		os.Remove(partPath)
+		os.Remove(partPath + ".json")
+		return err
+	}
+	return os.Rename(partPath, t.path)
+}
+
+// archiveExts is the preference order of archives. xz is smaller than gzip.
+var archiveExts = []string{".tar.xz", ".tar.gz"}
+
 var ErrNotFoundFile = fmt.Errorf("not found file")
 
-func download(ctx context.Context, url string) (*os.File, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+// downloadManifest is saved next to the partial file to resume the download.
+type downloadManifest struct {
+	URL          string `json:"url"`
+	Size         int64  `json:"size"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	ChunkSize    int64  `json:"chunk_size"`
+	Done         []bool `json:"done"`
+}
+
+func (m *downloadManifest) validator() string {
+	if m.ETag != "" {
+		return m.ETag
+	}
+	return m.LastModified
+}
+
+func readManifest(path string) (*downloadManifest, error) {
+	b, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	var m downloadManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
 
-	resp, err := http.DefaultClient.Do(req)
+func writeManifest(path string, m *downloadManifest) error {
+	b, err := json.Marshal(m)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", b, 0o644); err != nil {
+		return err
 	}
-	if resp.StatusCode != http.StatusOK {
-		if resp.StatusCode == http.StatusNotFound {
-			return nil, ErrNotFoundFile
+	return os.Rename(path+".tmp", path)
+}
+
+var errRangeNotSupported = fmt.Errorf("range requests are not supported")
+
+// download downloads url to dst with parallel range requests.
+// Failed ranges are retried, and an interrupted download is resumed with the manifest next to dst.
+// If the server does not support range requests or the size is unknown, it is downloaded with a single request.
+func download(ctx context.Context, url, dst string) error {
+	var head *http.Response
+	err := retry(ctx, "HEAD "+url, func() error {
+		req, err := newRequest(ctx, http.MethodHead, url)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return requestError(err)
 		}
-		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return nil, fmt.Errorf("response status is %d and body is '%s'", resp.StatusCode, body)
+		switch resp.StatusCode {
+		case http.StatusOK:
+			head = resp
+		case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+			debugf(ctx, "HEAD %s is not allowed", url)
+		default:
+			return responseError(resp)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	if head != nil {
+		size = max(head.ContentLength, 0)
 	}
+	p := startProgress(ctx, "download "+filepath.Base(url), size)
+	defer p.finish()
+
+	if head == nil || head.ContentLength <= 0 || !strings.EqualFold(head.Header.Get("Accept-Ranges"), "bytes") {
+		debugf(ctx, "%s does not support range requests", url)
+		return downloadStream(ctx, url, dst, p)
+	}
+	err = downloadRanges(ctx, url, dst, head, p)
+	if errors.Is(err, errRangeNotSupported) {
+		debugf(ctx, "%v. download %s with a single request", err, url)
+		os.Remove(dst + ".json")
+		p.reset()
+		return downloadStream(ctx, url, dst, p)
+	}
+	return err
+}
 
-	size := resp.ContentLength
-	chunk := int(size / int64(maxWorkers))
-	if size%int64(maxWorkers) != 0 {
+// minChunkSize is the minimum size of a range request. Small files are downloaded with fewer workers.
+const minChunkSize = 2 << 20
+
+func rangeWorkers(size int64) int {
+	return int(min(max(size/minChunkSize, 1), int64(maxWorkers)))
+}
+
+func downloadStream(ctx context.Context, url, dst string, p *progress) error {
+	return retry(ctx, "GET "+url, func() error {
+		release, err := acquireConnection(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+		req, err := newRequest(ctx, http.MethodGet, url)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return requestError(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return responseError(resp)
+		}
+		file, err := os.Create(dst)
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(file, p.reader(resp.Body))
+		if err != nil {
+			file.Close()
+			p.add(-n)
+			return &retryableError{err: err}
+		}
+		if resp.ContentLength > 0 && n != resp.ContentLength {
+			file.Close()
+			p.add(-n)
+			return &retryableError{err: fmt.Errorf("%s is short: %d of %d bytes", url, n, resp.ContentLength)}
+		}
+		return file.Close()
+	})
+}
+
+func downloadRanges(ctx context.Context, url, dst string, head *http.Response, p *progress) error {
+	size := head.ContentLength
+	workers := rangeWorkers(size)
+	chunk := size / int64(workers)
+	if size%int64(workers) != 0 {
 		chunk++
 	}
+	manifestPath := dst + ".json"
+	manifest := &downloadManifest{
+		URL:          url,
+		Size:         size,
+		ETag:         head.Header.Get("ETag"),
+		LastModified: head.Header.Get("Last-Modified"),
+		ChunkSize:    chunk,
+		Done:         make([]bool, workers),
+	}
+	flag := os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	if saved, err := readManifest(manifestPath); err == nil {
+		fi, statErr := os.Stat(dst)
+		if statErr == nil && fi.Size() == size && saved.URL == url && saved.Size == size &&
+			saved.validator() == manifest.validator() && saved.ChunkSize > 0 {
+			manifest = saved
+			flag = os.O_RDWR
+			debugf(ctx, "resume %s", dst)
+		}
+	}
+
+	file, err := os.OpenFile(dst, flag, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	// chunks are written to their offsets directly, so memory usage does not depend on the file size.
+	if err := file.Truncate(size); err != nil {
+		return err
+	}
+	if err := writeManifest(manifestPath, manifest); err != nil {
+		return err
+	}
+
 	var (
 		wg   sync.WaitGroup
-		buf  = make([]bytes.Buffer, maxWorkers)
+		mu   sync.Mutex
 		errs error
 	)
-	for i := 0; i < maxWorkers; i++ {
-		i := i
+	addErr := func(err error) {
+		mu.Lock()
+		errs = errors.Join(errs, err)
+		mu.Unlock()
+	}
+	for i, done := range manifest.Done {
+		start := int64(i) * manifest.ChunkSize
+		if start >= size {
+			continue
+		}
+		end := min(start+manifest.ChunkSize, size) - 1
+		if done {
+			p.skip(end - start + 1)
+			continue
+		}
 		wg.Add(1)
 
 		go func() {
 			defer wg.Done()
 
-			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			err := retry(ctx, fmt.Sprintf("range %d-%d", start, end), func() error {
+				return downloadRange(ctx, manifest, file, start, end, p)
+			})
 			if err != nil {
-				errs = errors.Join(errs, err)
+				addErr(err)
 				return
 			}
-
-			if i+1 == maxWorkers {
-				req.Header.Set("Range", fmt.Sprintf("bytes=%d-", i*chunk))
-			} else {
-				req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", i*chunk, (i+1)*chunk-1))
-			}
-
-			resp, err := http.DefaultClient.Do(req)
+			mu.Lock()
+			manifest.Done[i] = true
+			err = writeManifest(manifestPath, manifest)
+			mu.Unlock()
 			if err != nil {
-				errs = errors.Join(errs, err)
-				return
-			}
-			defer resp.Body.Close()
-			if resp.StatusCode != http.StatusPartialContent {
-				errs = errors.Join(errs, fmt.Errorf("response status is %d", resp.StatusCode))
-				return
-			}
-			if _, err := io.Copy(&buf[i], resp.Body); err != nil {
-				errs = errors.Join(errs, err)
-				return
+				addErr(err)
 			}
 		}()
 	}
@@ -281,24 +683,49 @@ func download(ctx context.Context, url string) (*os.File, error) {
 	wg.Wait()
 
 	if errs != nil {
-		return nil, errs
+		return errs
 	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Remove(manifestPath)
+}
 
-	tmpFile, err := os.CreateTemp(os.TempDir(), "*.tar.gz")
+func downloadRange(ctx context.Context, manifest *downloadManifest, file *os.File, start, end int64, p *progress) error {
+	release, err := acquireConnection(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	for _, b := range buf {
-		if _, err := tmpFile.Write(b.Bytes()); err != nil {
-			return nil, err
-		}
+	defer release()
+	req, err := newRequest(ctx, http.MethodGet, manifest.URL)
+	if err != nil {
+		return err
 	}
-	if err := tmpFile.Close(); err != nil {
-		return nil, err
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	if validator := manifest.validator(); validator != "" {
+		req.Header.Set("If-Range", validator)
 	}
-	tmpFile, err = os.Open(tmpFile.Name())
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, err
+		return requestError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		if resp.StatusCode == http.StatusOK {
+			// the server ignores Range, or the file is changed when If-Range is set.
+			return errRangeNotSupported
+		}
+		return responseError(resp)
 	}
-	return tmpFile, nil
+	n, err := io.Copy(io.NewOffsetWriter(file, start), p.reader(io.LimitReader(resp.Body, end-start+1)))
+	if err != nil {
+		p.add(-n)
+		return &retryableError{err: err}
+	}
+	if n != end-start+1 {
+		p.add(-n)
+		return &retryableError{err: fmt.Errorf("range %d-%d is short: %d bytes", start, end, n)}
+	}
+	return nil
 }
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// BenchmarkDownloadExtract downloads and extracts the tarball served with range support.
+// peak-heap-bytes is the memory ceiling. It should not grow with the size of the tarball.
+func BenchmarkDownloadExtract(b *testing.B) {
+	quiet = true
+	for _, size := range []int64{8 << 20, 32 << 20, 128 << 20} {
+		b.Run(formatBytes(size), func(b *testing.B) {
+			srcDir := b.TempDir()
+			archive := writeRandomTarball(b, filepath.Join(srcDir, "node.tar.gz"), size)
+			srv := httptest.NewServer(http.FileServer(http.Dir(srcDir)))
+			defer srv.Close()
+			ctx := testContext()
+
+			b.SetBytes(archive)
+			b.ReportAllocs()
+			b.ResetTimer()
+			var peak uint64
+			for i := 0; i < b.N; i++ {
+				dir := b.TempDir()
+				dst := filepath.Join(dir, "node.tar.gz")
+				peak = max(peak, peakHeap(func() {
+					if err := download(ctx, srv.URL+"/node.tar.gz", dst); err != nil {
+						b.Fatal(err)
+					}
+					if err := extractTarball(ctx, dst, ".tar.gz", dir); err != nil {
+						b.Fatal(err)
+					}
+				}))
+			}
+			b.ReportMetric(float64(peak), "peak-heap-bytes")
+			if limit := uint64(32 << 20); peak > limit {
+				b.Errorf("peak heap %s exceeds %s", formatBytes(int64(peak)), formatBytes(int64(limit)))
+			}
+		})
+	}
+}
+
+// peakHeap returns the peak of the heap in use while f runs.
+func peakHeap(f func()) uint64 {
+	runtime.GC()
+	var (
+		peak uint64
+		wg   sync.WaitGroup
+		done = make(chan struct{})
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		var m runtime.MemStats
+		for {
+			runtime.ReadMemStats(&m)
+			peak = max(peak, m.HeapInuse)
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	f()
+	close(done)
+	wg.Wait()
+	return peak
+}
+
+// writeRandomTarball writes a .tar.gz which has an incompressible file of size bytes, and returns the size of the archive.
+func writeRandomTarball(tb testing.TB, path string, size int64) int64 {
+	tb.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+	gw, err := gzip.NewWriterLevel(f, gzip.BestSpeed)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tw := tar.NewWriter(gw)
+	if err := tw.WriteHeader(&tar.Header{Name: "node-v20.0.0-linux-x64/bin/node", Mode: 0o755, Size: size, Typeflag: tar.TypeReg}); err != nil {
+		tb.Fatal(err)
+	}
+	if _, err := io.CopyN(tw, rand.New(rand.NewSource(1)), size); err != nil {
+		tb.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return fi.Size()
+}
+
+// testContext returns the context with loggers discarding outputs.
+func testContext() context.Context {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, loggerOutKey{}, log.New(io.Discard, "", 0))
+	return context.WithValue(ctx, loggerErrKey{}, log.New(io.Discard, "", 0))
+}
+
+func TestFetchTarballFallback(t *testing.T) {
+	quiet = true
+	p := platform{os: "linux", arch: "x64"}
+	const index = `[{"version":"v20.11.0","files":["linux-x64"],"lts":"Iron"}]`
+	const name = "node-v20.11.0-linux-x64.tar.gz"
+	cached := []byte("cached tarball")
+	sum := sha256.Sum256(cached)
+	cachedSHA256 := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		skip     bool
+		fallback bool
+		want     error
+	}{
+		{
+			name:     "index unavailable",
+			files:    map[string]string{},
+			fallback: true,
+		},
+		{
+			name: "checksum mismatch",
+			files: map[string]string{
+				"/index.json":              index,
+				"/v20.11.0/" + shasumsFile: fmt.Sprintf("%064x  %s\n", 0, name),
+				"/v20.11.0/" + name:        "remote tarball",
+			},
+			skip: true,
+			want: ErrChecksumMismatch,
+		},
+		{
+			name: "invalid signature",
+			files: map[string]string{
+				"/index.json":                       index,
+				"/v20.11.0/" + shasumsFile:          cachedSHA256 + "  " + name + "\n",
+				"/v20.11.0/" + shasumsFile + ".sig": "broken signature",
+				"/v20.11.0/" + name:                 string(cached),
+			},
+		},
+		{
+			name: "no checksum",
+			files: map[string]string{
+				"/index.json":              index,
+				"/v20.11.0/" + shasumsFile: fmt.Sprintf("%064x  node-v20.11.0-linux-arm64.tar.gz\n", 0),
+			},
+			skip: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			base := filepath.Join(home, nvsDir)
+			path := cachePath(base, Version{Major: 20, Minor: 11}, p.String(), cachedSHA256, ".tar.gz")
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, cached, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, ok := tt.files[r.URL.Path]
+				if !ok {
+					http.NotFound(w, r)
+					return
+				}
+				w.Write([]byte(body))
+			}))
+			defer srv.Close()
+			defer func(u string) { channelURLs[releaseChannel] = u }(channelURLs[releaseChannel])
+			channelURLs[releaseChannel] = srv.URL + "/"
+			indexLoads.Delete(releaseChannel)
+			defer indexLoads.Delete(releaseChannel)
+			defer func(skip bool) { insecureSkipVerify = skip }(insecureSkipVerify)
+			insecureSkipVerify = tt.skip
+
+			v, err := parseVersionString("20")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tb, err := fetchTarball(testContext(), base, v, p)
+			if tt.fallback {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tb.path != path {
+					t.Errorf("path = %s, want %s", tb.path, path)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("%s is used", tb.path)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var explainJSONArg bool
+
+var ExplainCmd = &cobra.Command{
+	Use:   "explain [dir]",
+	Short: "Explain how the Node version is resolved",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		if err := Explain(cmd.Context(), dir); err != nil {
+			fatal(cmd.Context(), err)
+		}
+	},
+}
+
+func init() {
+	ExplainCmd.Flags().BoolVar(&explainJSONArg, "json", false, "output json")
+}
+
+type explanation struct {
+	Steps      []resolutionStep `json:"steps"`
+	Source     string           `json:"source,omitempty"`
+	Path       string           `json:"path,omitempty"`
+	Constraint string           `json:"constraint,omitempty"`
+	Range      string           `json:"range,omitempty"`
+	Platform   string           `json:"platform"`
+	Installed  string           `json:"installed,omitempty"`
+	Remote     string           `json:"remote,omitempty"`
+	Error      string           `json:"error,omitempty"`
+}
+
+func Explain(ctx context.Context, dir string) error {
+	baseDir, err := checkInit()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+
+	res, err := resolveVersion(ctx, baseDir, dir)
+	e := explanation{Steps: res.steps, Platform: hostPlatform().String()}
+	if e.Steps == nil {
+		e.Steps = []resolutionStep{}
+	}
+	if err == nil {
+		err = explainVersion(ctx, baseDir, res.used, &e)
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	if explainJSONArg {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(e)
+	}
+	os.Stdout.WriteString(e.String())
+	return nil
+}
+
+func explainVersion(ctx context.Context, baseDir string, used *resolutionStep, e *explanation) error {
+	e.Source = used.Source
+	e.Path = used.Path
+	e.Constraint = used.Value
+
+	spec, err := resolveVersionString(ctx, used.Value)
+	if err != nil {
+		return err
+	}
+	e.Range = spec.rng.String()
+	if spec.channel != releaseChannel {
+		e.Range = fmt.Sprintf("%s (%s channel)", e.Range, spec.channel)
+	}
+
+	name, err := findLocalVersion(baseDir, spec)
+	if err == nil {
+		e.Installed = name
+		return nil
+	}
+	if !errors.Is(err, ErrNotFoundLocalVersion) {
+		return err
+	}
+	target, err := findTarget(ctx, spec)
+	if err != nil {
+		return err
+	}
+	e.Remote = target.Version.String()
+	return nil
+}
+
+func (e explanation) String() string {
+	var buf strings.Builder
+	for _, step := range e.Steps {
+		fmt.Fprintf(&buf, "%s (%s)\n", step.Path, step.Source)
+		if step.Value != "" {
+			fmt.Fprintf(&buf, "  value: %s\n", step.Value)
+		}
+		if step.Skipped != "" {
+			fmt.Fprintf(&buf, "  skipped: %s\n", step.Skipped)
+		}
+	}
+	if e.Constraint != "" {
+		fmt.Fprintf(&buf, "\nconstraint: %s (%s)\n", e.Constraint, e.Source)
+	}
+	if e.Range != "" {
+		fmt.Fprintf(&buf, "range: %s\n", e.Range)
+	}
+	fmt.Fprintf(&buf, "platform: %s\n", e.Platform)
+	switch {
+	case e.Installed != "":
+		fmt.Fprintf(&buf, "installed: %s\n", e.Installed)
+	case e.Remote != "":
+		fmt.Fprintf(&buf, "remote: %s (not installed)\n", e.Remote)
+	}
+	if e.Error != "" {
+		fmt.Fprintf(&buf, "error: %s\n", e.Error)
+	}
+	return buf.String()
+}
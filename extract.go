@@ -0,0 +1,201 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+)
+
+var ErrUnsafeArchive = fmt.Errorf("unsafe archive")
+
+// extract extracts the tarball into dir.
+// Entries escaping dir, such as "../" paths, absolute paths and links to the outside, are rejected.
+// Mode and mtime of files and directories are restored.
+func extract(file io.Reader, ext, dir string) error {
+	var r io.Reader
+	switch ext {
+	case ".tar.xz":
+		xr, err := xz.NewReader(bufio.NewReader(file))
+		if err != nil {
+			return fmt.Errorf("new xz reader: %w", err)
+		}
+		r = xr
+	case ".tar.gz":
+		gr, err := gzip.NewReader(bufio.NewReader(file))
+		if err != nil {
+			return fmt.Errorf("new gzip reader: %w", err)
+		}
+		defer gr.Close()
+		r = gr
+	default:
+		return fmt.Errorf("%s is not supported archive", ext)
+	}
+
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	// directories are restored at the end, because read-only directories cannot have entries.
+	var dirs []*tar.Header
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read tarball: %w", err)
+		}
+		path, err := extractPath(root, hdr.Name)
+		if err != nil {
+			return err
+		}
+		mode := hdr.FileInfo().Mode().Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return err
+			}
+			dirs = append(dirs, hdr)
+		case tar.TypeReg:
+			if err := prepareEntry(path); err != nil {
+				return err
+			}
+			if err := createFile(tr, path, mode); err != nil {
+				return err
+			}
+			if err := os.Chtimes(path, hdr.AccessTime, hdr.ModTime); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := prepareEntry(path); err != nil {
+				return err
+			}
+			parent, err := filepath.EvalSymlinks(filepath.Dir(path))
+			if err != nil {
+				return err
+			}
+			if !linkInRoot(root, parent, hdr.Linkname) {
+				return fmt.Errorf("%w: %s links to %s", ErrUnsafeArchive, hdr.Name, hdr.Linkname)
+			}
+			if err := os.Symlink(hdr.Linkname, path); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			src, err := extractPath(root, hdr.Linkname)
+			if err != nil {
+				return err
+			}
+			fi, err := os.Lstat(src)
+			if err != nil {
+				return fmt.Errorf("%s links to %s: %w", hdr.Name, hdr.Linkname, err)
+			}
+			if !fi.Mode().IsRegular() {
+				return fmt.Errorf("%w: %s links to non regular file %s", ErrUnsafeArchive, hdr.Name, hdr.Linkname)
+			}
+			if err := prepareEntry(path); err != nil {
+				return err
+			}
+			if err := os.Link(src, path); err != nil {
+				return err
+			}
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			return fmt.Errorf("%w: %s has unsupported type %q", ErrUnsafeArchive, hdr.Name, hdr.Typeflag)
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		hdr := dirs[i]
+		path := filepath.Join(root, hdr.Name)
+		if err := os.Chmod(path, hdr.FileInfo().Mode().Perm()); err != nil {
+			return err
+		}
+		if err := os.Chtimes(path, hdr.AccessTime, hdr.ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractPath returns the path of the entry in root. It rejects the entry escaping root.
+func extractPath(root, name string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") {
+		return "", fmt.Errorf("%w: %s is absolute path", ErrUnsafeArchive, name)
+	}
+	path := filepath.Join(root, name)
+	if !within(root, path) {
+		return "", fmt.Errorf("%w: %s is outside", ErrUnsafeArchive, name)
+	}
+	// a symlink extracted before must not lead the entry to the outside.
+	parent, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if err == nil && !within(root, parent) {
+		return "", fmt.Errorf("%w: %s is outside through symlink", ErrUnsafeArchive, name)
+	}
+	return path, nil
+}
+
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// linkInRoot reports whether the symlink in parent stays in root.
+// ".." is only allowed at the beginning of link, so that it does not go up from another symlink.
+func linkInRoot(root, parent, link string) bool {
+	if filepath.IsAbs(link) {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(link), "/")
+	i := 0
+	for i < len(parts) && parts[i] == ".." {
+		i++
+	}
+	if slices.Contains(parts[i:], "..") {
+		return false
+	}
+	return within(root, filepath.Join(parent, link))
+}
+
+// prepareEntry creates the parent directory and removes the existing entry not to write through it.
+func prepareEntry(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if fi, err := os.Lstat(path); err == nil && !fi.IsDir() {
+		return os.Remove(path)
+	}
+	return nil
+}
+
+// createFile writes r into the new file at path with mode.
+func createFile(r io.Reader, path string, mode os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return fmt.Errorf("copy to %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	// the mode of OpenFile is masked by umask.
+	return os.Chmod(path, mode)
+}
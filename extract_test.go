@@ -0,0 +1,228 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ulikunitz/xz"
+)
+
+type tarEntry struct {
+	hdr  tar.Header
+	body string
+}
+
+func tarFile(name, body string) tarEntry {
+	return tarEntry{hdr: tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(body))}, body: body}
+}
+
+func tarDir(name string) tarEntry {
+	return tarEntry{hdr: tar.Header{Name: name, Typeflag: tar.TypeDir, Mode: 0o755}}
+}
+
+func tarSymlink(name, link string) tarEntry {
+	return tarEntry{hdr: tar.Header{Name: name, Typeflag: tar.TypeSymlink, Linkname: link}}
+}
+
+func tarHardlink(name, link string) tarEntry {
+	return tarEntry{hdr: tar.Header{Name: name, Typeflag: tar.TypeLink, Linkname: link}}
+}
+
+func tarGz(t *testing.T, entries ...tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	writeTar(t, gw, entries)
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func writeTar(t *testing.T, w interface{ Write([]byte) (int, error) }, entries []tarEntry) {
+	t.Helper()
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		if err := tw.WriteHeader(&e.hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// extractDir extracts the archive into a new directory in a parent directory,
+// so that files escaping the directory can be found in the parent.
+func extractDir(t *testing.T, archive []byte, ext string) (parent, dir string, err error) {
+	t.Helper()
+	parent = t.TempDir()
+	dir = filepath.Join(parent, "root")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return parent, dir, extract(bytes.NewReader(archive), ext, dir)
+}
+
+func TestExtractUnsafe(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+	}{
+		{"parent", []tarEntry{tarFile("../evil", "x")}},
+		{"nested parent", []tarEntry{tarFile("node/../../evil", "x")}},
+		{"absolute", []tarEntry{tarFile("/tmp/evil", "x")}},
+		{"absolute symlink", []tarEntry{tarSymlink("link", "/etc")}},
+		{"parent symlink", []tarEntry{tarSymlink("link", "..")}},
+		{"nested parent symlink", []tarEntry{tarDir("a/"), tarSymlink("a/link", "../..")}},
+		{"parent in the middle of symlink", []tarEntry{tarDir("a/"), tarSymlink("a/link", "b/../../..")}},
+		// d/up points to the root, and d/up/up2 would point to the parent of the root.
+		{"chained symlinks", []tarEntry{tarDir("d/"), tarSymlink("d/up", ".."), tarSymlink("d/up/up2", "..")}},
+		{"write through symlink", []tarEntry{tarDir("d/"), tarSymlink("d/up", ".."), tarSymlink("d/up/up2", ".."), tarFile("d/up/up2/evil", "x")}},
+		{"hardlink to parent", []tarEntry{tarHardlink("link", "../evil")}},
+		{"hardlink to absolute", []tarEntry{tarHardlink("link", "/etc/passwd")}},
+		{"hardlink to directory", []tarEntry{tarDir("d/"), tarHardlink("link", "d")}},
+		{"hardlink to symlink", []tarEntry{tarFile("f", "x"), tarSymlink("s", "f"), tarHardlink("link", "s")}},
+		{"char device", []tarEntry{{hdr: tar.Header{Name: "null", Typeflag: tar.TypeChar, Devmajor: 1, Devminor: 3}}}},
+		{"fifo", []tarEntry{{hdr: tar.Header{Name: "fifo", Typeflag: tar.TypeFifo}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent, _, err := extractDir(t, tarGz(t, tt.entries...), ".tar.gz")
+			if !errors.Is(err, ErrUnsafeArchive) {
+				t.Errorf("err = %v, want %v", err, ErrUnsafeArchive)
+			}
+			if _, err := os.Lstat(filepath.Join(parent, "evil")); !os.IsNotExist(err) {
+				t.Errorf("evil is written outside: %v", err)
+			}
+		})
+	}
+}
+
+func TestExtractSafeLinks(t *testing.T) {
+	archive := tarGz(t,
+		tarDir("node/"),
+		tarDir("node/bin/"),
+		tarFile("node/lib/node_modules/npm/bin/npm-cli.js", "npm"),
+		tarSymlink("node/bin/npm", "../lib/node_modules/npm/bin/npm-cli.js"),
+		tarSymlink("node/lib/self", "."),
+		tarFile("node/lib/self/through", "through"),
+		tarFile("node/bin/node", "node"),
+		tarHardlink("node/bin/nodejs", "node/bin/node"),
+	)
+	_, dir, err := extractDir(t, archive, ".tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		"node/bin/npm":     "npm",
+		"node/lib/through": "through",
+		"node/bin/nodejs":  "node",
+	} {
+		b, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			t.Errorf("read %s: %v", path, err)
+			continue
+		}
+		if string(b) != want {
+			t.Errorf("%s = %q, want %q", path, b, want)
+		}
+	}
+}
+
+func TestExtractModeAndMtime(t *testing.T) {
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	bin := tarFile("node/bin/node", "node")
+	bin.hdr.Mode = 0o755
+	bin.hdr.ModTime = mtime
+	readme := tarFile("node/README.md", "readme")
+	readme.hdr.Mode = 0o444
+	readme.hdr.ModTime = mtime
+	include := tarDir("node/include/")
+	include.hdr.Mode = 0o555
+	include.hdr.ModTime = mtime
+	header := tarFile("node/include/node.h", "header")
+	header.hdr.ModTime = mtime
+
+	for _, ext := range archiveExts {
+		t.Run(ext, func(t *testing.T) {
+			entries := []tarEntry{include, bin, readme, header}
+			var archive []byte
+			if ext == ".tar.xz" {
+				var buf bytes.Buffer
+				xw, err := xz.NewWriter(&buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				writeTar(t, xw, entries)
+				if err := xw.Close(); err != nil {
+					t.Fatal(err)
+				}
+				archive = buf.Bytes()
+			} else {
+				archive = tarGz(t, entries...)
+			}
+			_, dir, err := extractDir(t, archive, ext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the read-only directory must be writable to remove the temporary directory.
+			t.Cleanup(func() { os.Chmod(filepath.Join(dir, "node/include"), 0o755) })
+
+			for path, mode := range map[string]os.FileMode{
+				"node/bin/node":       0o755,
+				"node/README.md":      0o444,
+				"node/include":        0o555,
+				"node/include/node.h": 0o644,
+			} {
+				fi, err := os.Stat(filepath.Join(dir, path))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if fi.Mode().Perm() != mode {
+					t.Errorf("mode of %s = %v, want %v", path, fi.Mode().Perm(), mode)
+				}
+				if !fi.ModTime().Equal(mtime) {
+					t.Errorf("mtime of %s = %v, want %v", path, fi.ModTime(), mtime)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractBroken(t *testing.T) {
+	archive := tarGz(t, tarFile("node/bin/node", string(bytes.Repeat([]byte("node"), 4096))))
+
+	var garbage bytes.Buffer
+	gw := gzip.NewWriter(&garbage)
+	gw.Write(bytes.Repeat([]byte{0xff}, 1024))
+	gw.Close()
+
+	tests := []struct {
+		name    string
+		archive []byte
+		ext     string
+	}{
+		{"truncated gzip", archive[:len(archive)/2], ".tar.gz"},
+		{"truncated header", archive[:20], ".tar.gz"},
+		{"invalid header", garbage.Bytes(), ".tar.gz"},
+		{"not xz", archive, ".tar.xz"},
+		{"unsupported ext", archive, ".zip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := extractDir(t, tt.archive, tt.ext); err == nil {
+				t.Error("broken archive is extracted")
+			}
+		})
+	}
+}
@@ -3,11 +3,15 @@ module github.com/komem3/nvs
 go 1.22.0
 
 require (
+	github.com/ProtonMail/go-crypto v1.1.3
 	github.com/spf13/cobra v1.8.0
-	golang.org/x/net v0.23.0
+	github.com/ulikunitz/xz v0.5.12
+	golang.org/x/sys v0.16.0
 )
 
 require (
+	github.com/cloudflare/circl v1.3.7 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
+	golang.org/x/crypto v0.17.0 // indirect
 )
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type release struct {
+	Version  Version
+	Date     string
+	Files    []string
+	Npm      string
+	LTS      string
+	Security bool
+}
+
+// hasFile reports whether the tarball for the platform is published.
+func (r *release) hasFile(p platform) bool {
+	return slices.Contains(r.Files, p.indexFile())
+}
+
+type ltsName string
+
+// UnmarshalJSON accepts false for non LTS releases and the codename for LTS releases.
+func (l *ltsName) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		*l = ltsName(name)
+		return nil
+	}
+	var b2 bool
+	if err := json.Unmarshal(b, &b2); err != nil {
+		return fmt.Errorf("lts field is %s: %w", b, err)
+	}
+	*l = ""
+	return nil
+}
+
+type indexRelease struct {
+	Version  string   `json:"version"`
+	Date     string   `json:"date"`
+	Files    []string `json:"files"`
+	Npm      string   `json:"npm"`
+	LTS      ltsName  `json:"lts"`
+	Security bool     `json:"security"`
+}
+
+// fetchReleaseIndex returns the release index of the channel.
+// The index is cached, and the cache within indexTTL is used without requests.
+// An expired cache is revalidated with ETag or Last-Modified. --refresh fetches the index regardless of the cache.
+// In offline mode, the cache is used regardless of its age.
+// The releases are sorted by version in descending order.
+func fetchReleaseIndex(ctx context.Context, channel string) ([]release, error) {
+	load, _ := indexLoads.LoadOrStore(channel, sync.OnceValues(func() (*indexCache, error) {
+		return loadIndexCache(ctx, channel)
+	}))
+	cache, err := load.(func() (*indexCache, error))()
+	if err != nil {
+		return nil, err
+	}
+	indexes := cache.Releases
+
+	releases := make([]release, 0, len(indexes))
+	for _, index := range indexes {
+		v, err := parseVersion(index.Version)
+		if err != nil {
+			debugf(ctx, "%s is skipped: %v", index.Version, err)
+			continue
+		}
+		releases = append(releases, release{
+			Version:  v,
+			Date:     index.Date,
+			Files:    index.Files,
+			Npm:      index.Npm,
+			LTS:      string(index.LTS),
+			Security: index.Security,
+		})
+	}
+	slices.SortFunc(releases, func(l, r release) int {
+		return r.Version.Compare(l.Version)
+	})
+	return releases, nil
+}
+
+// indexLoads has the loader of each channel, so that the index is loaded once in the process
+// even if versions are downloaded concurrently.
+var indexLoads sync.Map
+
+func loadIndexCache(ctx context.Context, channel string) (*indexCache, error) {
+	cache, err := readIndexCache(channel)
+	if err != nil && !os.IsNotExist(err) {
+		debugf(ctx, "read cached index of %s channel: %v", channel, err)
+	}
+	switch {
+	case offline:
+		if cache == nil {
+			return nil, fmt.Errorf("%w: no cached index of %s channel: %w", ErrOffline, channel, err)
+		}
+		debugf(ctx, "use the index of %s channel fetched at %s", channel, cache.FetchedAt)
+	case cache != nil && !refreshIndex && time.Since(cache.FetchedAt) < indexTTL:
+		debugf(ctx, "use the index of %s channel fetched at %s", channel, cache.FetchedAt)
+	default:
+		if refreshIndex {
+			cache = nil
+		}
+		cache, err = fetchIndexCache(ctx, channel, cache)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeIndexCache(channel, cache); err != nil {
+			debugf(ctx, "cache index of %s channel: %v", channel, err)
+		}
+	}
+	return cache, nil
+}
+
+// indexCacheDir has the last fetched index of each channel in the cache directory.
+const indexCacheDir = "index"
+
+type indexCache struct {
+	// File is index.json or index.tab.
+	File         string         `json:"file"`
+	ETag         string         `json:"etag,omitempty"`
+	LastModified string         `json:"last_modified,omitempty"`
+	FetchedAt    time.Time      `json:"fetched_at"`
+	Releases     []indexRelease `json:"releases"`
+}
+
+func indexCachePath(channel string) (string, error) {
+	baseDir, err := checkInit()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(baseDir, cacheDir, indexCacheDir, channel+".json"), nil
+}
+
+func readIndexCache(channel string) (*indexCache, error) {
+	path, err := indexCachePath(channel)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cache indexCache
+	if err := json.Unmarshal(b, &cache); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &cache, nil
+}
+
+func writeIndexCache(channel string, cache *indexCache) error {
+	path, err := indexCachePath(channel)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	// other processes may write the same cache, so the temporary file is unique.
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// refreshIndex ignores the cached index.
+var refreshIndex bool
+
+var errNotModified = fmt.Errorf("not modified")
+
+var errIndexUnavailable = fmt.Errorf("release index is unavailable")
+
+// fetchIndexCache fetches index.json of the channel. index.tab is used when index.json is unavailable.
+// cache is revalidated if it is not nil.
+func fetchIndexCache(ctx context.Context, channel string, cache *indexCache) (*indexCache, error) {
+	var errs error
+	for _, name := range []string{"index.json", "index.tab"} {
+		var validator *indexCache
+		if cache != nil && cache.File == name {
+			validator = cache
+		}
+		fetched, err := fetchIndex(ctx, channel, name, validator)
+		if errors.Is(err, errNotModified) {
+			debugf(ctx, "%s of %s channel is not modified", name, channel)
+			cache.FetchedAt = time.Now()
+			return cache, nil
+		}
+		if err == nil {
+			return fetched, nil
+		}
+		debugf(ctx, "fetch %s: %v", name, err)
+		errs = errors.Join(errs, err)
+	}
+	return nil, fmt.Errorf("%w: %w", errIndexUnavailable, errs)
+}
+
+// fetchIndex fetches the index file. It returns errNotModified if validator is still valid.
+func fetchIndex(ctx context.Context, channel, name string, validator *indexCache) (*indexCache, error) {
+	u, err := url.JoinPath(channelURLs[channel], name)
+	if err != nil {
+		return nil, err
+	}
+	var cache *indexCache
+	err = retry(ctx, "GET "+u, func() error {
+		req, err := newRequest(ctx, http.MethodGet, u)
+		if err != nil {
+			return err
+		}
+		if validator != nil {
+			if validator.ETag != "" {
+				req.Header.Set("If-None-Match", validator.ETag)
+			}
+			if validator.LastModified != "" {
+				req.Header.Set("If-Modified-Since", validator.LastModified)
+			}
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return requestError(err)
+		}
+		defer resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusNotModified && validator != nil:
+			return errNotModified
+		case resp.StatusCode != http.StatusOK:
+			return responseError(resp)
+		}
+
+		var indexes []indexRelease
+		if name == "index.tab" {
+			indexes, err = parseIndexTab(resp.Body)
+		} else if err = json.NewDecoder(resp.Body).Decode(&indexes); err != nil {
+			err = fmt.Errorf("decode %s: %w", name, err)
+		}
+		if err != nil {
+			return &retryableError{err: err}
+		}
+		cache = &indexCache{
+			File:         name,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			FetchedAt:    time.Now(),
+			Releases:     indexes,
+		}
+		return nil
+	})
+	return cache, err
+}
+
+// parseIndexTab parses index.tab. "-" is an empty value.
+func parseIndexTab(r io.Reader) ([]indexRelease, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("index.tab is empty: %w", scanner.Err())
+	}
+	columns := make(map[string]int)
+	for i, name := range strings.Split(scanner.Text(), "\t") {
+		columns[name] = i
+	}
+	for _, name := range []string{"version", "files"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("index.tab has no %s column", name)
+		}
+	}
+
+	var indexes []indexRelease
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(fields) || fields[i] == "-" {
+				return ""
+			}
+			return fields[i]
+		}
+		index := indexRelease{
+			Version:  field("version"),
+			Date:     field("date"),
+			Npm:      field("npm"),
+			LTS:      ltsName(field("lts")),
+			Security: field("security") == "true",
+		}
+		if files := field("files"); files != "" {
+			index.Files = strings.Split(files, ",")
+		}
+		indexes = append(indexes, index)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read index.tab: %w", err)
+	}
+	return indexes, nil
+}
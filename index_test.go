@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFetchReleaseIndexConcurrent(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.Mkdir(filepath.Join(home, nvsDir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`[{"version":"v20.11.0","files":["linux-x64"],"lts":"Iron"},{"version":"v21.6.0","files":["linux-x64"],"lts":false}]`))
+	}))
+	defer srv.Close()
+	const channel = "test"
+	defer func(u string) { channelURLs[channel] = u }(channelURLs[channel])
+	channelURLs[channel] = srv.URL + "/"
+	indexLoads.Delete(channel)
+	defer indexLoads.Delete(channel)
+
+	ctx := testContext()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			releases, err := fetchReleaseIndex(ctx, channel)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(releases) != 2 || releases[0].Version.String() != "v21.6.0" {
+				t.Errorf("releases = %+v", releases)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := requests.Load(); n != 1 {
+		t.Errorf("index is fetched %d times", n)
+	}
+	if _, err := readIndexCache(channel); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWriteIndexCacheConcurrent(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.Mkdir(filepath.Join(home, nvsDir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			releases := make([]indexRelease, 100*(i+1))
+			for j := range releases {
+				releases[j] = indexRelease{Version: "v20.11.0", Files: []string{"linux-x64"}}
+			}
+			if err := writeIndexCache("release", &indexCache{File: "index.json", Releases: releases}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := readIndexCache("release"); err != nil {
+		t.Fatalf("broken cache: %v", err)
+	}
+	path, err := indexCachePath("release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary files are left: %v", entries)
+	}
+}
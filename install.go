@@ -40,7 +40,7 @@ func Install(ctx context.Context, args []string) error {
 		}
 		return err
 	}
-	parsedVersion, err := parseVersionString(string(v))
+	parsedVersion, err := resolveVersionString(ctx, string(v))
 	if err != nil {
 		return err
 	}
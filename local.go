@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DownloadFile installs the tarball in the local file for p. The version and the platform are inferred from the archive.
+// The file is verified with sha256, or SHASUMS256.txt in the same directory if sha256 is empty.
+func DownloadFile(ctx context.Context, file, sha256 string, p platform) error {
+	base, err := checkInit()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(archiveExts, func(ext string) bool { return strings.HasSuffix(file, ext) })
+	if i < 0 {
+		return fmt.Errorf("%s is not %s", file, strings.Join(archiveExts, " or "))
+	}
+	ext := archiveExts[i]
+	if sha256 == "" {
+		sha256, err = localChecksum(file)
+		if err != nil {
+			return err
+		}
+	}
+	if sha256 != "" {
+		infof(ctx, "verify %s", file)
+		if err := verifyChecksum(ctx, file, sha256); err != nil {
+			return err
+		}
+	} else {
+		warnf(ctx, "%s is not verified, because --sha256 is not set and %s is not next to it", file, shasumsFile)
+		sha256, err = fileSHA256(ctx, file)
+		if err != nil {
+			return err
+		}
+	}
+
+	dir, err := newStagingDir(base)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := extractTarball(ctx, file, ext, dir); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(entries) != 1 || !entries[0].IsDir() {
+		return fmt.Errorf("%s does not have a top directory", file)
+	}
+	fromDir := filepath.Join(dir, entries[0].Name())
+	v, err := inferVersion(fromDir)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	archivePlatform, err := localPlatform(entries[0].Name(), p)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	target := installPath(base, v.String(), p)
+	unlock, err := lockInstall(ctx, base, target)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return installVersion(ctx, base, dir, fromDir, target, installReceipt{
+		Version:  v.String(),
+		File:     filepath.Base(file),
+		URL:      fileURL(file),
+		SHA256:   strings.ToLower(sha256),
+		Platform: archivePlatform.String(),
+	})
+}
+
+// localChecksum returns the checksum of file in SHASUMS256.txt in the same directory.
+// It returns an empty string if SHASUMS256.txt does not exist.
+func localChecksum(file string) (string, error) {
+	f, err := os.Open(filepath.Join(filepath.Dir(file), shasumsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer f.Close()
+	checksums, err := parseChecksums(f)
+	if err != nil {
+		return "", err
+	}
+	checksum, ok := checksums[filepath.Base(file)]
+	if !ok {
+		return "", fmt.Errorf("%s next to %s has no checksum of it", shasumsFile, file)
+	}
+	return checksum, nil
+}
+
+// localPlatform returns the platform in the directory name such as "node-v20.11.0-linux-x64".
+// It must be one of the candidates of p. The directory without the platform in the name is assumed to be for p.
+func localPlatform(name string, p platform) (platform, error) {
+	_, namePlatform, err := splitDirName(name)
+	if err != nil || namePlatform == (platform{}) {
+		return p, nil
+	}
+	if slices.Contains(p.candidates(), namePlatform) {
+		return namePlatform, nil
+	}
+	return platform{}, fmt.Errorf("%s is for %s, not %s. Use --os, --arch and --libc to install it for another platform", name, namePlatform, p)
+}
+
+// DownloadDir installs the extracted Node.js in the local directory for p. The version and the platform are inferred from the directory.
+func DownloadDir(ctx context.Context, src string, p platform) error {
+	base, err := checkInit()
+	if err != nil {
+		return err
+	}
+	src, err = filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	v, err := inferVersion(src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	dirPlatform, err := localPlatform(filepath.Base(src), p)
+	if err != nil {
+		return err
+	}
+
+	dir, err := newStagingDir(base)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	fromDir := filepath.Join(dir, "node-"+v.String())
+	infof(ctx, "copy %s", src)
+	if err := copyDir(src, fromDir); err != nil {
+		return err
+	}
+
+	target := installPath(base, v.String(), p)
+	unlock, err := lockInstall(ctx, base, target)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return installVersion(ctx, base, dir, fromDir, target, installReceipt{
+		Version:  v.String(),
+		File:     filepath.Base(src),
+		URL:      fileURL(src),
+		Platform: dirPlatform.String(),
+	})
+}
+
+func fileURL(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+const nodeVersionHeader = "include/node/node_version.h"
+
+// inferVersion returns the version of the extracted Node.js in dir.
+// The version is read from the directory name such as "node-v20.11.0-linux-x64" and include/node/node_version.h.
+// The prerelease is only in the directory name.
+func inferVersion(dir string) (Version, error) {
+	nameVersion, nameErr := versionFromDirName(filepath.Base(dir))
+	headerVersion, headerErr := versionFromHeader(filepath.Join(dir, nodeVersionHeader))
+	switch {
+	case nameErr == nil && headerErr == nil:
+		if nameVersion.Major != headerVersion.Major || nameVersion.Minor != headerVersion.Minor || nameVersion.Patch != headerVersion.Patch {
+			return Version{}, fmt.Errorf("directory name is %s, but %s is %s", nameVersion, nodeVersionHeader, headerVersion)
+		}
+		return nameVersion, nil
+	case nameErr == nil:
+		return nameVersion, nil
+	case headerErr == nil:
+		return headerVersion, nil
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bin", "node")); err != nil {
+		return Version{}, fmt.Errorf("not Node.js: %w", err)
+	}
+	return Version{}, fmt.Errorf("cannot infer version: %v, %v", nameErr, headerErr)
+}
+
+// versionFromDirName parses the name such as "node-v20.11.0-linux-x64".
+func versionFromDirName(name string) (Version, error) {
+	version, _, err := splitDirName(name)
+	if err != nil {
+		return Version{}, err
+	}
+	return parseVersion(version)
+}
+
+// splitDirName splits the name such as "node-v20.11.0-linux-x64-musl" into the version and the platform.
+// The platform is zero if the name has no platform.
+func splitDirName(name string) (string, platform, error) {
+	rest, ok := strings.CutPrefix(name, "node-")
+	if !ok {
+		return "", platform{}, fmt.Errorf("%s is not node-<version>-<platform>", name)
+	}
+	for _, goos := range nodeOS {
+		i := strings.Index(rest, "-"+goos+"-")
+		if i <= 0 {
+			continue
+		}
+		parts := strings.SplitN(rest[i+1:], "-", 3)
+		p := platform{os: parts[0], arch: parts[1]}
+		if len(parts) == 3 {
+			p.libc = parts[2]
+		}
+		return rest[:i], p, nil
+	}
+	return rest, platform{}, nil
+}
+
+func versionFromHeader(path string) (Version, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Version{}, err
+	}
+	defer f.Close()
+	defines := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "#define" {
+			defines[fields[1]] = fields[2]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Version{}, err
+	}
+	var v Version
+	for _, part := range []struct {
+		name string
+		n    *int
+	}{
+		{"NODE_MAJOR_VERSION", &v.Major},
+		{"NODE_MINOR_VERSION", &v.Minor},
+		{"NODE_PATCH_VERSION", &v.Patch},
+	} {
+		n, err := strconv.Atoi(defines[part.name])
+		if err != nil {
+			return Version{}, fmt.Errorf("%s in %s: %w", part.name, path, err)
+		}
+		*part.n = n
+	}
+	if defines["NODE_VERSION_IS_RELEASE"] == "0" {
+		return Version{}, fmt.Errorf("%s is not a release, so the prerelease is unknown", v)
+	}
+	return v, nil
+}
+
+// copyDir copies the tree of src to dst with modes, mtimes and symlinks.
+func copyDir(src, dst string) error {
+	type dirInfo struct {
+		path string
+		info fs.FileInfo
+	}
+	var dirs []dirInfo
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			dirs = append(dirs, dirInfo{target, info})
+			return os.MkdirAll(target, 0o755)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			in, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer in.Close()
+			if err := createFile(in, target, info.Mode().Perm()); err != nil {
+				return err
+			}
+			return os.Chtimes(target, info.ModTime(), info.ModTime())
+		default:
+			return fmt.Errorf("%s is not a regular file", path)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	// modes of directories are set after copying their entries.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].info.Mode().Perm()); err != nil {
+			return err
+		}
+		if err := os.Chtimes(dirs[i].path, dirs[i].info.ModTime(), dirs[i].info.ModTime()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownloadFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, nvsDir, "versions"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	ctx := testContext()
+	quiet = true
+
+	writeTarball := func(dir, name string) string {
+		path := filepath.Join(dir, name+".tar.gz")
+		archive := tarGz(t,
+			tarFile(name+"/bin/node", "node"),
+			tarFile(name+"/"+nodeVersionHeader, "#define NODE_MAJOR_VERSION 20\n#define NODE_MINOR_VERSION 11\n#define NODE_PATCH_VERSION 0\n"),
+		)
+		if err := os.WriteFile(path, archive, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	host := hostPlatform()
+	other := platform{os: "aix", arch: "ppc64"}
+
+	t.Run("other platform", func(t *testing.T) {
+		file := writeTarball(t.TempDir(), "node-v20.11.0-"+other.String())
+		err := DownloadFile(ctx, file, "", host)
+		if err == nil || !strings.Contains(err.Error(), "is for "+other.String()) {
+			t.Errorf("err = %v", err)
+		}
+		if err := DownloadFile(ctx, file, "", other); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(home, nvsDir, platformsDir, other.String(), "v20.11.0", "bin", "node")); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("SHASUMS256.txt", func(t *testing.T) {
+		dir := t.TempDir()
+		file := writeTarball(dir, "node-v20.11.0-"+host.String())
+		shasums := filepath.Join(dir, shasumsFile)
+		if err := os.WriteFile(shasums, []byte(fmt.Sprintf("%064x  %s\n", 0, filepath.Base(file))), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := DownloadFile(ctx, file, "", host); !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("err = %v, want %v", err, ErrChecksumMismatch)
+		}
+
+		if err := os.WriteFile(shasums, []byte(fmt.Sprintf("%064x  other.tar.gz\n", 0)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := DownloadFile(ctx, file, "", host); err == nil {
+			t.Error("the file without checksum is installed")
+		}
+
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(b)
+		if err := os.WriteFile(shasums, []byte(hex.EncodeToString(sum[:])+"  "+filepath.Base(file)+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := DownloadFile(ctx, file, "", host); err != nil {
+			t.Fatal(err)
+		}
+		receipt, err := readInstallReceipt(filepath.Join(home, nvsDir, "versions", "v20.11.0"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if receipt.Platform != host.String() || receipt.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("receipt = %+v", receipt)
+		}
+	})
+}
+
+func TestSplitDirName(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		platform platform
+	}{
+		{"node-v20.11.0-linux-x64", "v20.11.0", platform{os: "linux", arch: "x64"}},
+		{"node-v20.11.0-linux-x64-musl", "v20.11.0", platform{os: "linux", arch: "x64", libc: "musl"}},
+		{"node-v21.0.0-rc.1-darwin-arm64", "v21.0.0-rc.1", platform{os: "darwin", arch: "arm64"}},
+		{"node-v20.11.0", "v20.11.0", platform{}},
+	}
+	for _, tt := range tests {
+		version, p, err := splitDirName(tt.name)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if version != tt.version || p != tt.platform {
+			t.Errorf("splitDirName(%s) = %s, %+v", tt.name, version, p)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// lockDir has lock files of versions being installed.
+const lockDir = "locks"
+
+// startedAt is used to find versions installed by other processes after this process started.
+var startedAt = time.Now()
+
+// errLocked is returned by lockHandle when another process has the lock.
+var errLocked = fmt.Errorf("locked by another process")
+
+// lockFile takes the exclusive lock of path. It waits while another process has the lock.
+func lockFile(ctx context.Context, path string) (unlock func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	err = lockHandle(ctx, f, false)
+	if errors.Is(err, errLocked) {
+		infof(ctx, "wait for another nvs process (%s)", filepath.Base(path))
+		err = lockHandle(ctx, f, true)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	debugf(ctx, "lock %s", path)
+	return func() {
+		unlockHandle(f)
+		f.Close()
+	}, nil
+}
+
+// lockInstall takes the lock of the install directory such as versions/v20.11.0 to download and install it.
+func lockInstall(ctx context.Context, base, target string) (unlock func(), err error) {
+	rel, err := filepath.Rel(base, target)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.ReplaceAll(filepath.ToSlash(rel), "/", "-")
+	return lockFile(ctx, filepath.Join(base, lockDir, name+".lock"))
+}
@@ -0,0 +1,24 @@
+//go:build !(unix && !solaris && !aix) && !windows
+
+package main
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"sync"
+)
+
+var warnNoLock sync.Once
+
+// lockHandle does not lock, because flock is not available.
+func lockHandle(ctx context.Context, _ *os.File, _ bool) error {
+	warnNoLock.Do(func() {
+		warnf(ctx, "file locks are not supported on %s. Do not run downloads of the same version concurrently", runtime.GOOS)
+	})
+	return nil
+}
+
+func unlockHandle(*os.File) error {
+	return nil
+}
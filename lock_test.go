@@ -0,0 +1,36 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockFile(t *testing.T) {
+	ctx := testContext()
+	path := filepath.Join(t.TempDir(), lockDir, "v20.0.0.lock")
+	unlock, err := lockFile(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked := make(chan func())
+	go func() {
+		unlock, err := lockFile(ctx, path)
+		if err != nil {
+			t.Error(err)
+		}
+		locked <- unlock
+	}()
+	select {
+	case <-locked:
+		t.Fatal("the lock is taken twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case unlock := <-locked:
+		unlock()
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lock is not released")
+	}
+}
@@ -29,6 +29,13 @@ func debugf(ctx context.Context, format string, args ...any) {
 	}
 }
 
+// progressf writes progress to stderr, so that it is not mixed with the output.
+func progressf(ctx context.Context, format string, args ...any) {
+	if verbose {
+		ctx.Value(loggerErrKey{}).(*log.Logger).Output(2, fmt.Sprintf(format, args...))
+	}
+}
+
 func warnf(ctx context.Context, format string, args ...any) {
 	ctx.Value(loggerErrKey{}).(*log.Logger).Output(2, fmt.Sprintf(format, args...))
 }
@@ -13,14 +13,32 @@ func main() {
 	ctx = context.WithValue(ctx, loggerOutKey{}, log.New(os.Stdout, "[nvs] ", 0))
 	ctx = context.WithValue(ctx, loggerErrKey{}, log.New(os.Stderr, "[nvs] ", 0))
 
-	rootCmd := &cobra.Command{Use: "nvs"}
+	rootCmd := &cobra.Command{
+		Use: "nvs",
+		PersistentPreRun: func(cmd *cobra.Command, _ []string) {
+			if err := loadConfig(cmd.Context()); err != nil {
+				fatal(cmd.Context(), err)
+			}
+			if err := cleanStaging(cmd.Context()); err != nil {
+				warnf(cmd.Context(), "clean staging: %v", err)
+			}
+		},
+	}
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "output debug log")
+	rootCmd.PersistentFlags().BoolVar(&refreshIndex, "refresh", false, "fetch the release index regardless of the cache")
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "use only installed versions and the cache")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "do not show progress")
+	rootCmd.PersistentFlags().BoolVar(&insecureSkipVerify, "insecure-skip-verify", false, "skip signature verification of release checksums")
 
+	rootCmd.AddCommand(CacheCmd)
 	rootCmd.AddCommand(DownloadCmd)
+	rootCmd.AddCommand(ExplainCmd)
 	rootCmd.AddCommand(InitCmd)
 	rootCmd.AddCommand(RunCmd)
+	rootCmd.AddCommand(ShellCmd)
 	rootCmd.AddCommand(UseCmd)
 	rootCmd.AddCommand(VersionsCmd)
 	rootCmd.AddCommand(InstallCmd)
+	rootCmd.AddCommand(KeysCmd)
 	rootCmd.ExecuteContext(ctx)
 }
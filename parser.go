@@ -2,237 +2,414 @@ package main
 
 import (
 	"fmt"
-	"math"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
-const (
-	emptyMark = iota
-	tiledeMark
-	hatMark
-	biggerMark
-	sameBiggerMark
-	smallerMark
-	sameSmallerMark
-	equalMark
-)
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+}
 
-type specifyVersion interface {
-	version() int
+func parseVersion(str string) (Version, error) {
+	var v Version
+	str = strings.TrimRight(strings.TrimLeft(strings.TrimSpace(str), "v"), "/")
+	if i := strings.IndexByte(str, '+'); i >= 0 {
+		str = str[:i]
+	}
+	if i := strings.IndexByte(str, '-'); i >= 0 {
+		v.Prerelease = str[i+1:]
+		str = str[:i]
+		if v.Prerelease == "" {
+			return v, fmt.Errorf("%s has empty prerelease", str)
+		}
+	}
+	splits := strings.Split(str, ".")
+	if len(splits) != 3 {
+		return v, fmt.Errorf("%s is invalid format", str)
+	}
+	nums := make([]int, 3)
+	for i, numstr := range splits {
+		num, err := strconv.ParseInt(numstr, 10, 64)
+		if err != nil {
+			return v, fmt.Errorf("%s is invalid format: %w", str, err)
+		}
+		if num < 0 {
+			return v, fmt.Errorf("%s is invalid format", str)
+		}
+		nums[i] = int(num)
+	}
+	v.Major, v.Minor, v.Patch = nums[0], nums[1], nums[2]
+	return v, nil
 }
 
-type (
-	bigger      int
-	smaller     int
-	sameBigger  int
-	sameSmaller int
-	same        int
-	asterisk    struct{}
-)
+func (v Version) String() string {
+	if v.Prerelease != "" {
+		return fmt.Sprintf("v%d.%d.%d-%s", v.Major, v.Minor, v.Patch, v.Prerelease)
+	}
+	return fmt.Sprintf("v%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
 
-func (i bigger) version() int      { return int(i) }
-func (i smaller) version() int     { return int(i) }
-func (i sameBigger) version() int  { return int(i) }
-func (i sameSmaller) version() int { return int(i) }
-func (i same) version() int        { return int(i) }
-func (i asterisk) version() int    { return 0 }
-
-type version struct {
-	major specifyVersion
-	minor specifyVersion
-	patch specifyVersion
-}
-
-func compareVersion(number int, version specifyVersion) (match, confirm bool) {
-	vint := version.version()
-	switch v := version.(type) {
-	case bigger:
-		return vint < number, vint < number
-	case smaller:
-		return vint > number, vint > number
-	case sameBigger:
-		if vint == number {
-			return true, false
-		}
-		return vint < number, vint < number
-	case sameSmaller:
-		if vint == number {
-			return true, false
-		}
-		return vint > number, vint > number
-	case same:
-		return vint == number, false
-	case asterisk:
-		return true, false
-	default:
-		panic(fmt.Sprintf("unknown type %T", v))
+// Compare returns a negative number when v < o, zero when v == o and a positive number when v > o.
+func (v Version) Compare(o Version) int {
+	switch {
+	case v.Major != o.Major:
+		return v.Major - o.Major
+	case v.Minor != o.Minor:
+		return v.Minor - o.Minor
+	case v.Patch != o.Patch:
+		return v.Patch - o.Patch
 	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
 }
 
-func parseVersionString(str string) (*version, error) {
-	str = strings.Trim(str, "v/")
-	splits := strings.Split(str, ".")
-	if len(splits) == 0 {
-		return nil, fmt.Errorf("%s is not support format", str)
+func comparePrerelease(l, r string) int {
+	switch {
+	case l == r:
+		return 0
+	case l == "":
+		return 1
+	case r == "":
+		return -1
+	}
+	ls, rs := strings.Split(l, "."), strings.Split(r, ".")
+	for i := 0; i < len(ls) && i < len(rs); i++ {
+		if ls[i] == rs[i] {
+			continue
+		}
+		ln, lerr := strconv.ParseUint(ls[i], 10, 64)
+		rn, rerr := strconv.ParseUint(rs[i], 10, 64)
+		switch {
+		case lerr == nil && rerr == nil:
+			if ln < rn {
+				return -1
+			}
+			return 1
+		case lerr == nil:
+			return -1
+		case rerr == nil:
+			return 1
+		}
+		return strings.Compare(ls[i], rs[i])
 	}
+	return len(ls) - len(rs)
+}
+
+type comparator struct {
+	op      string
+	version Version
+}
+
+// versionRange is a union (||) of comparator sets. All comparators of a set must match.
+type versionRange [][]comparator
+
+var anyComparator = comparator{op: ">="}
 
-	first := splits[0]
-	if len(first) == 0 {
-		return nil, fmt.Errorf("%s is not support format", str)
+// never matches. used for ranges such as "<0" or ">*".
+var noneComparator = comparator{op: "<"}
+
+func (c comparator) match(v Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	default:
+		return cmp == 0
 	}
+}
 
-	var typ int
-	switch first[0] {
-	case '~':
-		typ = tiledeMark
-	case '^':
-		typ = hatMark
-	case '<':
-		if strings.HasPrefix(first, "<=") {
-			typ = sameSmallerMark
-		} else {
-			typ = smallerMark
+// match reports whether v satisfies r. Like node-semver, a prerelease version only matches
+// a set that has a comparator with the same major, minor and patch and a prerelease,
+// unless includePrerelease is set.
+func (r versionRange) match(v Version, includePrerelease bool) bool {
+	for _, set := range r {
+		match := true
+		allowed := v.Prerelease == "" || includePrerelease
+		for _, c := range set {
+			if !c.match(v) {
+				match = false
+				break
+			}
+			if c.version.Prerelease != "" && c.version.Major == v.Major && c.version.Minor == v.Minor && c.version.Patch == v.Patch {
+				allowed = true
+			}
 		}
-	case '>':
-		if strings.HasPrefix(first, ">=") {
-			typ = sameBiggerMark
-		} else {
-			typ = biggerMark
+		if match && allowed {
+			return true
 		}
 	}
+	return false
+}
 
-	v := &version{
-		major: asterisk{},
-		minor: asterisk{},
-		patch: asterisk{},
+func (r versionRange) String() string {
+	sets := make([]string, 0, len(r))
+	for _, set := range r {
+		cs := make([]string, 0, len(set))
+		for _, c := range set {
+			cs = append(cs, c.op+strings.TrimLeft(c.version.String(), "v"))
+		}
+		sets = append(sets, strings.Join(cs, " "))
 	}
-	for i, numstr := range splits {
-		if numstr == "X" || numstr == "x" {
-			continue
+	return strings.Join(sets, " || ")
+}
+
+var (
+	hyphenRegex   = regexp.MustCompile(`^(\S+)\s+-\s+(\S+)$`)
+	operatorRegex = regexp.MustCompile(`(<=|>=|<|>|=|~>|~|\^)\s+`)
+)
+
+type versionSpec struct {
+	raw     string
+	channel string
+	// alias is a symbolic version such as "lts/iron". rng is nil until the alias is resolved.
+	alias string
+	rng   versionRange
+}
+
+func (s *versionSpec) match(v Version) bool {
+	if s.rng == nil || versionChannel(v) != s.channel {
+		return false
+	}
+	return s.rng.match(v, s.channel != releaseChannel)
+}
+
+func (s *versionSpec) String() string {
+	return s.raw
+}
+
+// withChannel returns the spec resolved on the given channel. An empty channel keeps the current one.
+func (s *versionSpec) withChannel(channel string) (*versionSpec, error) {
+	if channel == "" || channel == s.channel {
+		return s, nil
+	}
+	if !isChannel(channel) {
+		return nil, fmt.Errorf("unknown channel %s", channel)
+	}
+	if s.alias != "" {
+		return nil, fmt.Errorf("%s is only available in %s channel", s.alias, releaseChannel)
+	}
+	return &versionSpec{raw: s.raw, channel: channel, rng: s.rng}, nil
+}
+
+func parseVersionString(str string) (*versionSpec, error) {
+	str = strings.TrimSpace(str)
+	if isChannel(str) {
+		return &versionSpec{raw: str, channel: str, rng: versionRange{{anyComparator}}}, nil
+	}
+	if isAlias(str) {
+		return &versionSpec{raw: str, channel: releaseChannel, alias: str}, nil
+	}
+	rng, err := parseVersionRange(str)
+	if err != nil {
+		return nil, err
+	}
+	spec := &versionSpec{raw: str, channel: releaseChannel, rng: rng}
+	for _, set := range rng {
+		for _, c := range set {
+			if channel := versionChannel(c.version); channel != releaseChannel {
+				spec.channel = channel
+			}
+		}
+	}
+	return spec, nil
+}
+
+func parseVersionRange(str string) (versionRange, error) {
+	var r versionRange
+	for _, part := range strings.Split(str, "||") {
+		set, err := parseComparatorSet(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("%s is not support format: %w", str, err)
 		}
-		num, err := strconv.ParseInt(strings.TrimLeft(numstr, "^~<>="), 10, 64)
+		r = append(r, set)
+	}
+	return r, nil
+}
+
+func parseComparatorSet(str string) ([]comparator, error) {
+	if str == "" {
+		return []comparator{anyComparator}, nil
+	}
+	if m := hyphenRegex.FindStringSubmatch(str); m != nil {
+		from, err := parsePartial(m[1])
 		if err != nil {
 			return nil, err
 		}
-		switch i {
-		case 0:
-			switch typ {
-			case biggerMark:
-				if len(splits) == 1 {
-					v.major = bigger(num)
-				} else {
-					v.major = sameBigger(num)
-				}
-			case smallerMark:
-				if len(splits) == 1 {
-					v.major = smaller(num)
-				} else {
-					v.major = sameSmaller(num)
-				}
-			case sameBiggerMark:
-				v.major = sameBigger(num)
-			case sameSmallerMark:
-				v.major = sameSmaller(num)
-			default:
-				v.major = same(num)
-			}
-		case 1:
-			switch typ {
-			case biggerMark:
-				if len(splits) == 2 {
-					v.minor = bigger(num)
-				} else {
-					v.minor = sameBigger(num)
-				}
-			case smallerMark:
-				if len(splits) == 2 {
-					v.minor = smaller(num)
-				} else {
-					v.minor = sameSmaller(num)
-				}
-			case sameBiggerMark:
-				v.minor = sameBigger(num)
-			case sameSmallerMark:
-				v.minor = sameSmaller(num)
-			default:
-				v.minor = same(num)
-			}
-		case 2:
-			switch typ {
-			case emptyMark:
-				v.patch = same(num)
-			case tiledeMark:
-				v.patch = sameBigger(num)
-			case hatMark:
-				if v.major == same(0) && v.minor == same(0) {
-					v.patch = same(num)
-				} else {
-					v.patch = sameBigger(num)
-				}
-			case biggerMark:
-				v.patch = bigger(num)
-			case smallerMark:
-				v.patch = smaller(num)
-			case sameBiggerMark:
-				v.patch = sameBigger(num)
-			case sameSmallerMark:
-				v.patch = sameSmaller(num)
-			}
+		to, err := parsePartial(m[2])
+		if err != nil {
+			return nil, err
 		}
+		return []comparator{from.lowerBound(), to.upperBound(true)}, nil
 	}
 
-	return v, nil
+	var set []comparator
+	for _, field := range strings.Fields(operatorRegex.ReplaceAllString(str, "$1")) {
+		cs, err := parseSimple(field)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, cs...)
+	}
+	return set, nil
 }
 
-func compareVersionString(numberStrs []string, v *version) (bool, error) {
-	if len(numberStrs) != 3 {
-		return false, fmt.Errorf("%v is invalid format", numberStrs)
-	}
-	major, err := strconv.ParseInt(numberStrs[0], 10, 64)
-	if err != nil {
-		return false, err
+func parseSimple(str string) ([]comparator, error) {
+	var op string
+	for _, prefix := range []string{">=", "<=", "~>", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(str, prefix) {
+			op = prefix
+			str = str[len(prefix):]
+			break
+		}
 	}
-	minor, err := strconv.ParseInt(numberStrs[1], 10, 64)
+	p, err := parsePartial(str)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	patch, err := strconv.ParseInt(numberStrs[2], 10, 64)
-	if err != nil {
-		return false, err
+
+	switch op {
+	case "", "=":
+		if p.n == 0 {
+			return []comparator{anyComparator}, nil
+		}
+		if p.n == 3 {
+			return []comparator{{op: "=", version: p.Version}}, nil
+		}
+		return []comparator{p.lowerBound(), p.upperBound(false)}, nil
+	case "~", "~>":
+		if p.n == 0 {
+			return []comparator{anyComparator}, nil
+		}
+		upper := Version{Major: p.Major, Minor: p.Minor + 1, Prerelease: "0"}
+		if p.n == 1 {
+			upper = Version{Major: p.Major + 1, Prerelease: "0"}
+		}
+		return []comparator{p.lowerBound(), {op: "<", version: upper}}, nil
+	case "^":
+		if p.n == 0 {
+			return []comparator{anyComparator}, nil
+		}
+		var upper Version
+		switch {
+		case p.Major > 0 || p.n == 1:
+			upper = Version{Major: p.Major + 1, Prerelease: "0"}
+		case p.Minor > 0 || p.n == 2:
+			upper = Version{Minor: p.Minor + 1, Prerelease: "0"}
+		default:
+			upper = Version{Patch: p.Patch + 1, Prerelease: "0"}
+		}
+		return []comparator{p.lowerBound(), {op: "<", version: upper}}, nil
+	case ">":
+		switch p.n {
+		case 0:
+			return []comparator{noneComparator}, nil
+		case 3:
+			return []comparator{{op: ">", version: p.Version}}, nil
+		}
+		next := p.next()
+		next.Prerelease = ""
+		return []comparator{{op: ">=", version: next}}, nil
+	case ">=":
+		return []comparator{p.lowerBound()}, nil
+	case "<":
+		if p.n == 0 {
+			return []comparator{noneComparator}, nil
+		}
+		if p.n < 3 {
+			p.Prerelease = "0"
+		}
+		return []comparator{{op: "<", version: p.Version}}, nil
+	case "<=":
+		return []comparator{p.upperBound(true)}, nil
 	}
+	return nil, fmt.Errorf("unknown operator %s", op)
+}
+
+type partialVersion struct {
+	Version
+	// n is the number of specified segments. "20.x" and "20" are 1.
+	n int
+}
 
-	match, confirm := compareVersion(int(major), v.major)
-	if confirm {
-		return true, nil
+func parsePartial(str string) (partialVersion, error) {
+	var p partialVersion
+	str = strings.TrimLeft(str, "v=")
+	if i := strings.IndexByte(str, '+'); i >= 0 {
+		str = str[:i]
 	}
-	if !match {
-		return false, nil
+	if str == "" {
+		return p, nil
 	}
-	match, confirm = compareVersion(int(minor), v.minor)
-	if confirm {
-		return true, nil
+	if i := strings.IndexByte(str, '-'); i >= 0 {
+		p.Prerelease = str[i+1:]
+		str = str[:i]
+		if p.Prerelease == "" {
+			return p, fmt.Errorf("%s has empty prerelease", str)
+		}
 	}
-	if !match {
-		return false, nil
+	splits := strings.Split(str, ".")
+	if len(splits) > 3 {
+		return p, fmt.Errorf("%s has too many segments", str)
 	}
-	match, _ = compareVersion(int(patch), v.patch)
+	nums := []*int{&p.Major, &p.Minor, &p.Patch}
+	for i, numstr := range splits {
+		if numstr == "x" || numstr == "X" || numstr == "*" {
+			break
+		}
+		if p.n != i {
+			return p, fmt.Errorf("%s is invalid format", str)
+		}
+		num, err := strconv.ParseInt(numstr, 10, 64)
+		if err != nil {
+			return p, err
+		}
+		if num < 0 {
+			return p, fmt.Errorf("%s is invalid format", str)
+		}
+		*nums[i] = int(num)
+		p.n++
+	}
+	if p.Prerelease != "" && p.n != 3 {
+		return p, fmt.Errorf("%s has prerelease without patch version", str)
+	}
+	return p, nil
+}
 
-	return match, nil
+func (p partialVersion) lowerBound() comparator {
+	return comparator{op: ">=", version: p.Version}
 }
 
-func mustParse(numberStr string) int {
-	num, err := strconv.ParseInt(numberStr, 10, 64)
-	if err != nil {
-		panic(err)
+// upperBound returns the comparator for the end of the partial version.
+// "1.2" is "<1.3.0-0" and "1.2.3" is "<=1.2.3" when inclusive.
+func (p partialVersion) upperBound(inclusive bool) comparator {
+	switch p.n {
+	case 0:
+		return anyComparator
+	case 3:
+		if inclusive {
+			return comparator{op: "<=", version: p.Version}
+		}
+		return comparator{op: "=", version: p.Version}
 	}
-	return int(num)
+	return comparator{op: "<", version: p.next()}
 }
 
-func calcPriority(splitVersion []string) int {
-	var priority int
-	for i, verStr := range splitVersion {
-		priority += mustParse(verStr) * int(math.Pow(10, float64(3-i)))
+// next returns the lowest version after the partial version including prereleases.
+func (p partialVersion) next() Version {
+	switch p.n {
+	case 1:
+		return Version{Major: p.Major + 1, Prerelease: "0"}
+	case 2:
+		return Version{Major: p.Major, Minor: p.Minor + 1, Prerelease: "0"}
 	}
-	return priority
+	return p.Version
 }
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseVersionRange(t *testing.T) {
+	tests := []struct {
+		rng      string
+		match    []string
+		notMatch []string
+	}{
+		{">=18 <21", []string{"18.0.0", "20.99.0"}, []string{"17.9.9", "21.0.0", "21.0.0-rc.1"}},
+		{"^18 || ^20", []string{"18.1.0", "20.11.0"}, []string{"19.0.0", "21.0.0", "17.0.0"}},
+		{"18 - 20", []string{"18.0.0", "20.11.0"}, []string{"17.9.9", "21.0.0"}},
+		{"18.1 - 20.2.3", []string{"18.1.0", "20.2.3"}, []string{"18.0.9", "20.2.4"}},
+		{"20", []string{"20.0.0", "20.11.0"}, []string{"19.9.9", "21.0.0"}},
+		{"20.x", []string{"20.11.0"}, []string{"21.0.0"}},
+		{"*", []string{"0.0.1", "20.11.0"}, nil},
+		{"", []string{"20.11.0"}, nil},
+		{"=20.11.0", []string{"20.11.0"}, []string{"20.11.1"}},
+		{"v20.11.0", []string{"20.11.0"}, []string{"20.11.1"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0", "0.2.2"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4", "0.0.2"}},
+		{"^0.0", []string{"0.0.0", "0.0.9"}, []string{"0.1.0"}},
+		{"^0", []string{"0.0.1", "0.9.0"}, []string{"1.0.0"}},
+		{"~0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0"}},
+		{"~0.0.3", []string{"0.0.3", "0.0.9"}, []string{"0.1.0"}},
+		{"~1", []string{"1.0.0", "1.9.0"}, []string{"2.0.0"}},
+		{"~> 1.2", []string{"1.2.0", "1.2.9"}, []string{"1.3.0"}},
+		{">1.2", []string{"1.3.0"}, []string{"1.2.9", "1.2.0"}},
+		{">1.2.3", []string{"1.2.4"}, []string{"1.2.3"}},
+		{"<=1.2", []string{"1.2.9", "1.0.0"}, []string{"1.3.0"}},
+		{"<1.2", []string{"1.1.9"}, []string{"1.2.0"}},
+		{">= 18", []string{"18.0.0"}, []string{"17.9.9"}},
+		{">*", nil, []string{"0.0.0", "20.11.0"}},
+		{"<0", nil, []string{"0.0.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rng, func(t *testing.T) {
+			r, err := parseVersionRange(tt.rng)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.match {
+				if !r.match(mustParseVersion(t, s), false) {
+					t.Errorf("%s should match %s (%s)", tt.rng, s, r)
+				}
+			}
+			for _, s := range tt.notMatch {
+				if r.match(mustParseVersion(t, s), false) {
+					t.Errorf("%s should not match %s (%s)", tt.rng, s, r)
+				}
+			}
+		})
+	}
+}
+
+func TestParseVersionRangeError(t *testing.T) {
+	for _, rng := range []string{"a", "1.2.3.4", "1.2-rc.1", "20.0.0-", "^-1"} {
+		if _, err := parseVersionRange(rng); err == nil {
+			t.Errorf("%s should be invalid", rng)
+		}
+	}
+}
+
+func TestVersionRangePrerelease(t *testing.T) {
+	tests := []struct {
+		rng               string
+		version           string
+		includePrerelease bool
+		want              bool
+	}{
+		{">=20.0.0", "21.0.0-rc.1", false, false},
+		{">=20.0.0", "21.0.0-rc.1", true, true},
+		{">=21.0.0-rc.1", "21.0.0-rc.2", false, true},
+		{">=21.0.0-rc.1", "21.0.0", false, true},
+		// only prereleases of the same major, minor and patch match.
+		{">=21.0.0-rc.1", "21.0.1-rc.1", false, false},
+		{"^21.0.0-rc.1", "21.0.0-rc.10", false, true},
+		{"21.0.0-rc.2", "21.0.0-rc.2", false, true},
+		{"21.0.0-rc.2", "21.0.0-rc.10", false, false},
+		{"20", "20.0.0-rc.1", false, false},
+		{"<21", "21.0.0-rc.1", true, false},
+	}
+	for _, tt := range tests {
+		r, err := parseVersionRange(tt.rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.match(mustParseVersion(t, tt.version), tt.includePrerelease); got != tt.want {
+			t.Errorf("%s match %s (includePrerelease=%v) = %v, want %v", tt.rng, tt.version, tt.includePrerelease, got, tt.want)
+		}
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	// in ascending order.
+	versions := []string{
+		"1.0.0-0",
+		"1.0.0-1",
+		"1.0.0-2",
+		"1.0.0-10",
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0-rc.2",
+		"1.0.0-rc.10",
+		"1.0.0",
+		"1.0.1",
+		"1.1.0",
+		"2.0.0",
+		"10.0.0",
+	}
+	for i := range versions {
+		for j := range versions {
+			l, r := mustParseVersion(t, versions[i]), mustParseVersion(t, versions[j])
+			got := l.Compare(r)
+			switch {
+			case i < j && got >= 0, i == j && got != 0, i > j && got <= 0:
+				t.Errorf("Compare(%s, %s) = %d", versions[i], versions[j], got)
+			}
+		}
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Version
+	}{
+		{"v20.11.0", Version{Major: 20, Minor: 11}},
+		{"20.11.0/", Version{Major: 20, Minor: 11}},
+		{"v21.0.0-rc.1", Version{Major: 21, Prerelease: "rc.1"}},
+		{"v22.0.0-nightly20240101abcdef", Version{Major: 22, Prerelease: "nightly20240101abcdef"}},
+		{"v20.11.0+build", Version{Major: 20, Minor: 11}},
+	}
+	for _, tt := range tests {
+		got, err := parseVersion(tt.in)
+		if err != nil {
+			t.Errorf("parseVersion(%s): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseVersion(%s) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+	for _, in := range []string{"20.11", "v20.x.0", "v-1.0.0", "v20.0.0-"} {
+		if _, err := parseVersion(in); err == nil {
+			t.Errorf("parseVersion(%s) should fail", in)
+		}
+	}
+}
+
+func mustParseVersion(t *testing.T, s string) Version {
+	t.Helper()
+	v, err := parseVersion(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
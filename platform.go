@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"debug/elf"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// platform is the target of Node.js binaries. Its string is a part of the tarball name such as "linux-x64-musl".
+type platform struct {
+	os   string
+	arch string
+	// libc is "musl" for musl based Linux such as Alpine. It is empty for glibc.
+	libc string
+}
+
+const muslLibc = "musl"
+
+// nodeOS maps GOOS to the OS name of Node.js.
+var nodeOS = map[string]string{
+	"linux":   "linux",
+	"darwin":  "darwin",
+	"aix":     "aix",
+	"solaris": "sunos",
+	"illumos": "sunos",
+}
+
+// nodeArch maps GOARCH to the arch name of Node.js. arm is armv6l on ARMv6 CPUs.
+var nodeArch = map[string]string{
+	"amd64":   "x64",
+	"386":     "x86",
+	"arm64":   "arm64",
+	"arm":     "armv7l",
+	"ppc64":   "ppc64",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+	"riscv64": "riscv64",
+	"loong64": "loong64",
+}
+
+func (p platform) String() string {
+	if p.libc != "" {
+		return p.os + "-" + p.arch + "-" + p.libc
+	}
+	return p.os + "-" + p.arch
+}
+
+// indexFile returns the key of files field in the release index.
+func (p platform) indexFile() string {
+	if p.os == "darwin" {
+		return "osx-" + p.arch + "-tar"
+	}
+	return p.String()
+}
+
+// candidates returns the platforms to try in order.
+// darwin-arm64 falls back to darwin-x64 which runs on Rosetta 2, because old releases have no arm64 tarball.
+func (p platform) candidates() []platform {
+	if p.os == "darwin" && p.arch == "arm64" {
+		return []platform{p, {os: p.os, arch: "x64"}}
+	}
+	return []platform{p}
+}
+
+// hostPlatform is the platform of this machine.
+var hostPlatform = sync.OnceValue(func() platform {
+	p := platform{os: runtime.GOOS, arch: runtime.GOARCH}
+	if name, ok := nodeOS[p.os]; ok {
+		p.os = name
+	}
+	if name, ok := nodeArch[p.arch]; ok {
+		p.arch = name
+	}
+	// GOARCH is arm on both ARMv6 and ARMv7. /proc/cpuinfo of ARMv6 reports "CPU architecture: 7", so uname is used.
+	if runtime.GOARCH == "arm" && unameMachine() == "armv6l" {
+		p.arch = "armv6l"
+	}
+	if p.os == "linux" && isMusl() {
+		p.libc = muslLibc
+	}
+	return p
+})
+
+// parsePlatform returns the platform overridden by the flags. Empty values are the host's.
+// GOOS and GOARCH names are accepted as well as Node.js names.
+func parsePlatform(goos, arch, libc string) (platform, error) {
+	host := hostPlatform()
+	p := host
+	if goos != "" {
+		p.os = nodeName(nodeOS, goos)
+		if p.os == "" {
+			return platform{}, fmt.Errorf("unknown os %s", goos)
+		}
+	}
+	if arch != "" {
+		p.arch = nodeName(nodeArch, arch)
+		if p.arch == "" {
+			if arch != "armv6l" {
+				return platform{}, fmt.Errorf("unknown arch %s", arch)
+			}
+			p.arch = arch
+		}
+	}
+	switch libc {
+	case "":
+		// libc of the host does not matter for the other platforms.
+		if p.os != host.os || p.arch != host.arch {
+			p.libc = ""
+		}
+	case "glibc":
+		p.libc = ""
+	case muslLibc:
+		p.libc = muslLibc
+	default:
+		return platform{}, fmt.Errorf("unknown libc %s", libc)
+	}
+	if p.libc != "" && p.os != "linux" {
+		return platform{}, fmt.Errorf("%s is only for linux", p.libc)
+	}
+	return p, nil
+}
+
+func nodeName(names map[string]string, name string) string {
+	if n, ok := names[name]; ok {
+		return n
+	}
+	for _, n := range names {
+		if n == name {
+			return n
+		}
+	}
+	return ""
+}
+
+// isMusl reports whether the dynamic linker of /bin/sh is musl.
+func isMusl() bool {
+	f, err := elf.Open("/bin/sh")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+		interp := make([]byte, prog.Filesz)
+		if _, err := prog.ReadAt(interp, 0); err != nil {
+			return false
+		}
+		return strings.Contains(string(interp), "ld-musl")
+	}
+	return false
+}
+
+// findPlatform returns the channel and the platform of the tarball published for the release.
+// The unofficial builds are used for the platforms which the channel does not publish, such as musl, armv6l and riscv64.
+func findPlatform(ctx context.Context, channel string, target *release, p platform) (string, platform, error) {
+	var unofficial *release
+	for _, c := range p.candidates() {
+		if target.hasFile(c) {
+			return channel, c, nil
+		}
+		if channel != releaseChannel {
+			infof(ctx, "%s has no %s tarball", target.Version, c.indexFile())
+			continue
+		}
+		if unofficial == nil {
+			unofficial = &release{}
+			releases, err := fetchReleaseIndex(ctx, unofficialChannel)
+			if err != nil {
+				debugf(ctx, "unofficial builds: %v", err)
+			}
+			for _, r := range releases {
+				if r.Version == target.Version {
+					unofficial = &r
+					break
+				}
+			}
+		}
+		if unofficial.hasFile(c) {
+			infof(ctx, "use %s tarball of unofficial builds", c)
+			return unofficialChannel, c, nil
+		}
+		infof(ctx, "%s has no %s tarball", target.Version, c.indexFile())
+	}
+	return "", platform{}, fmt.Errorf("%s has no %s tarball", target.Version, p.indexFile())
+}
@@ -0,0 +1,12 @@
+package main
+
+import "golang.org/x/sys/unix"
+
+// unameMachine returns the machine of uname such as "armv6l".
+func unameMachine() string {
+	var u unix.Utsname
+	if err := unix.Uname(&u); err != nil {
+		return ""
+	}
+	return unix.ByteSliceToString(u.Machine[:])
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// progressInterval is the interval of redrawing the progress bar on a terminal.
+	progressInterval = 200 * time.Millisecond
+	// progressLogInterval is the interval of progress log lines when stderr is not a terminal.
+	progressLogInterval = 5 * time.Second
+	progressBarWidth    = 20
+)
+
+var quiet bool
+
+// progressBar enables the live bar on a terminal. Otherwise progress is logged periodically.
+var progressBar = true
+
+// progress reports the progress of a phase such as download, verify and extract.
+// It is safe to add bytes from multiple goroutines.
+type progress struct {
+	ctx     context.Context
+	name    string
+	total   int64
+	current atomic.Int64
+	// skipped is the bytes done before start. They are not counted in the rate.
+	skipped atomic.Int64
+	start   time.Time
+	tty     bool
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+type progressLabelKey struct{}
+
+// withProgressLabel returns the context whose progress is prefixed with label,
+// such as the version of concurrent downloads.
+func withProgressLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, progressLabelKey{}, label)
+}
+
+// startProgress starts reporting. total is 0 if the size is unknown.
+func startProgress(ctx context.Context, name string, total int64) *progress {
+	if label, ok := ctx.Value(progressLabelKey{}).(string); ok {
+		name = label + ": " + name
+	}
+	p := &progress{
+		ctx:     ctx,
+		name:    name,
+		total:   total,
+		start:   time.Now(),
+		tty:     progressBar && isTerminal(os.Stderr),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if quiet {
+		close(p.stopped)
+		return p
+	}
+	go p.loop()
+	return p
+}
+
+func (p *progress) add(n int64) {
+	p.current.Add(n)
+}
+
+// skip adds bytes which are done before start, such as a resumed download.
+func (p *progress) skip(n int64) {
+	p.current.Add(n)
+	p.skipped.Add(n)
+}
+
+// reset clears the progress to start over.
+func (p *progress) reset() {
+	p.current.Store(0)
+	p.skipped.Store(0)
+}
+
+func (p *progress) reader(r io.Reader) io.Reader {
+	return &progressReader{r: r, p: p}
+}
+
+// finish stops reporting and draws the last state on a terminal.
+func (p *progress) finish() {
+	select {
+	case <-p.done:
+	default:
+		close(p.done)
+	}
+	<-p.stopped
+}
+
+func (p *progress) loop() {
+	defer close(p.stopped)
+	interval := progressLogInterval
+	if p.tty {
+		interval = progressInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if p.tty {
+				fmt.Fprintf(os.Stderr, "\r\x1b[K%s", p.String())
+			} else {
+				progressf(p.ctx, "%s", p.String())
+			}
+		case <-p.done:
+			if p.tty {
+				fmt.Fprintf(os.Stderr, "\r\x1b[K%s\n", p.String())
+			}
+			return
+		}
+	}
+}
+
+func (p *progress) String() string {
+	current := p.current.Load()
+	var rate float64
+	if elapsed := time.Since(p.start).Seconds(); elapsed > 0 {
+		rate = float64(current-p.skipped.Load()) / elapsed
+	}
+	if p.total <= 0 {
+		return fmt.Sprintf("%s %s %s/s", p.name, formatBytes(current), formatBytes(int64(rate)))
+	}
+
+	current = min(current, p.total)
+	filled := int(current * progressBarWidth / p.total)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+	eta := "-"
+	if rate > 0 {
+		eta = (time.Duration(float64(p.total-current)/rate) * time.Second).String()
+	}
+	return fmt.Sprintf("%s %3d%% [%s] %s/%s %s/s ETA %s",
+		p.name, current*100/p.total, bar, formatBytes(current), formatBytes(p.total), formatBytes(int64(rate)), eta)
+}
+
+type progressReader struct {
+	r io.Reader
+	p *progress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.p.add(int64(n))
+	return n, err
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// versionResolver reads a Node version from a file. read returns an empty string
+// when the file does not specify a Node version.
+type versionResolver struct {
+	file  string
+	field string
+	read  func(b []byte) (string, error)
+}
+
+// versionResolvers are consulted in order in each directory.
+var versionResolvers = []versionResolver{
+	{file: localVersionFile, read: readVersionFile},
+	{file: ".nvmrc", read: readVersionFile},
+	{file: ".tool-versions", read: readToolVersions},
+	{file: "package.json", field: "volta.node", read: readPackageJSON(func(p *packageJSON) string {
+		return p.Volta.Node
+	})},
+	{file: "package.json", field: "devEngines.runtime", read: readPackageJSON(func(p *packageJSON) string {
+		for _, runtime := range p.DevEngines.Runtime {
+			if runtime.Name == "node" {
+				return runtime.Version
+			}
+		}
+		return ""
+	})},
+	{file: "package.json", field: "engines.node", read: readPackageJSON(func(p *packageJSON) string {
+		return p.Engines.Node
+	})},
+}
+
+func (r versionResolver) String() string {
+	if r.field == "" {
+		return r.file
+	}
+	return r.file + " " + r.field
+}
+
+// readVersionFile returns the first line except comments.
+func readVersionFile(b []byte) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			return line, nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// readToolVersions reads the nodejs line of asdf. The first version is used when the line has fallback versions.
+func readToolVersions(b []byte) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && (fields[0] == "nodejs" || fields[0] == "node") {
+			return fields[1], nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+type packageJSON struct {
+	Engines struct {
+		Node string `json:"node"`
+	} `json:"engines"`
+	Volta struct {
+		Node string `json:"node"`
+	} `json:"volta"`
+	DevEngines struct {
+		Runtime devEngineRuntimes `json:"runtime"`
+	} `json:"devEngines"`
+}
+
+type devEngineRuntime struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type devEngineRuntimes []devEngineRuntime
+
+// UnmarshalJSON accepts both a single runtime object and an array of runtimes.
+func (r *devEngineRuntimes) UnmarshalJSON(b []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("[")) {
+		var runtimes []devEngineRuntime
+		if err := json.Unmarshal(b, &runtimes); err != nil {
+			return err
+		}
+		*r = runtimes
+		return nil
+	}
+	var runtime devEngineRuntime
+	if err := json.Unmarshal(b, &runtime); err != nil {
+		return err
+	}
+	*r = devEngineRuntimes{runtime}
+	return nil
+}
+
+func readPackageJSON(field func(p *packageJSON) string) func(b []byte) (string, error) {
+	return func(b []byte) (string, error) {
+		var p packageJSON
+		if err := json.Unmarshal(b, &p); err != nil {
+			return "", fmt.Errorf("parse package.json: %w", err)
+		}
+		return strings.TrimSpace(field(&p)), nil
+	}
+}
+
+const (
+	globalSource = "global"
+	versionEnv   = "NVS_VERSION"
+)
+
+type resolutionStep struct {
+	Source string `json:"source"`
+	Path   string `json:"path"`
+	Value  string `json:"value,omitempty"`
+	// Skipped is the reason why the step is not used.
+	Skipped string `json:"skipped,omitempty"`
+}
+
+type resolution struct {
+	steps []resolutionStep
+	// used is the step which decides the version. nil when no version is found.
+	used *resolutionStep
+}
+
+func (r *resolution) use(ctx context.Context, step resolutionStep) {
+	debugf(ctx, "use %s in %s", step.Source, step.Path)
+	r.steps = append(r.steps, step)
+	r.used = &step
+}
+
+func (r *resolution) skip(ctx context.Context, step resolutionStep, format string, args ...any) {
+	step.Skipped = fmt.Sprintf(format, args...)
+	debugf(ctx, "%s in %s is skipped: %s", step.Source, step.Path, step.Skipped)
+	r.steps = append(r.steps, step)
+}
+
+// resolveVersion uses NVS_VERSION when it is set. Otherwise, it walks from dir to the root directory with versionResolvers and falls back to the global version.
+// The returned resolution records every file which exists even if an error is returned.
+func resolveVersion(ctx context.Context, baseDir, dir string) (*resolution, error) {
+	res := &resolution{}
+	if v := strings.TrimSpace(os.Getenv(versionEnv)); v != "" {
+		step := resolutionStep{Source: versionEnv, Path: "environment", Value: v}
+		if _, err := parseVersionString(v); err != nil {
+			res.skip(ctx, step, "%v", err)
+			return res, fmt.Errorf("%s: %w", versionEnv, err)
+		}
+		res.use(ctx, step)
+		return res, nil
+	}
+
+	directory, err := filepath.Abs(dir)
+	if err != nil {
+		debugf(ctx, "get %s abs: %v", dir, err)
+		return res, resolveGlobalVersion(ctx, res, baseDir)
+	}
+	for {
+		files := make(map[string][]byte)
+		for _, resolver := range versionResolvers {
+			path := filepath.Join(directory, resolver.file)
+			b, ok := files[resolver.file]
+			if !ok {
+				b, err = os.ReadFile(path)
+				if err != nil && !os.IsNotExist(err) {
+					return res, err
+				}
+				files[resolver.file] = b
+			}
+			if b == nil {
+				continue
+			}
+			step := resolutionStep{Source: resolver.String(), Path: path}
+			v, err := resolver.read(b)
+			if err != nil {
+				res.skip(ctx, step, "%v", err)
+				continue
+			}
+			if v == "" {
+				res.skip(ctx, step, "no node version")
+				continue
+			}
+			step.Value = v
+			if _, err := parseVersionString(v); err != nil {
+				res.skip(ctx, step, "%v", err)
+				continue
+			}
+			res.use(ctx, step)
+			return res, nil
+		}
+
+		parent := filepath.Dir(directory)
+		if parent == directory {
+			return res, resolveGlobalVersion(ctx, res, baseDir)
+		}
+		directory = parent
+	}
+}
+
+func resolveGlobalVersion(ctx context.Context, res *resolution, baseDir string) error {
+	path := filepath.Join(baseDir, globalVersionFile)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFoundGlobalVersion
+		}
+		return err
+	}
+	step := resolutionStep{Source: globalSource, Path: path, Value: strings.TrimSpace(string(b))}
+	if _, err := parseVersionString(step.Value); err != nil {
+		res.skip(ctx, step, "%v", err)
+		return fmt.Errorf("global version: %w", err)
+	}
+	res.use(ctx, step)
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	maxRetries   = 5
+	retryBackoff = 500 * time.Millisecond
+	maxBackoff   = 30 * time.Second
+)
+
+type retryableError struct {
+	err error
+	// after is the wait specified by Retry-After. Zero means exponential backoff.
+	after time.Duration
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// errRetriesExhausted wraps the last error of retry, so that callers can tell the server was unavailable.
+var errRetriesExhausted = fmt.Errorf("gave up after %d retries", maxRetries)
+
+// retry calls f until it succeeds, it returns a non retryable error or it fails maxRetries times.
+func retry(ctx context.Context, name string, f func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := f()
+		var retryable *retryableError
+		if err == nil || !errors.As(err, &retryable) {
+			return err
+		}
+		if attempt >= maxRetries {
+			return fmt.Errorf("%w: %w", errRetriesExhausted, retryable.err)
+		}
+		wait := retryable.after
+		if wait == 0 {
+			wait = min(retryBackoff<<attempt, maxBackoff)
+			wait += rand.N(wait / 2)
+		}
+		debugf(ctx, "retry %s after %s: %v", name, wait, retryable.err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// requestError wraps the error of http.Client.Do. DNS errors are not retried, because they are mostly offline.
+func requestError(err error) error {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) || errors.Is(err, context.Canceled) {
+		return err
+	}
+	return &retryableError{err: err}
+}
+
+// isUnavailable reports whether err is caused by the network or the server, such as a DNS error or 5xx after retries.
+// Verification errors such as ErrChecksumMismatch are not.
+func isUnavailable(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.Is(err, errRetriesExhausted) || errors.As(err, &dnsErr)
+}
+
+// responseError returns the error of the unexpected response. 429 and 5xx are retryable.
+func responseError(resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s: %w", resp.Request.URL, ErrNotFoundFile)
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	err := fmt.Errorf("%s status is %d. response %s", resp.Request.URL, resp.StatusCode, body)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		return &retryableError{err: err, after: retryAfter(resp.Header.Get("Retry-After"))}
+	}
+	return err
+}
+
+// retryAfter parses Retry-After of seconds or HTTP date.
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return min(time.Duration(seconds)*time.Second, maxBackoff)
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return min(max(time.Until(t), 0), maxBackoff)
+	}
+	return 0
+}
@@ -2,21 +2,19 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"slices"
-	"strings"
 
 	"github.com/spf13/cobra"
 )
 
 var (
 	runVersionArg string
+	runChannelArg string
 	runVerboseArg bool
 )
 
@@ -50,6 +48,7 @@ var RunCmd = &cobra.Command{
 
 func init() {
 	RunCmd.Flags().StringVar(&runVersionArg, "version", autoVersion, "specify running version")
+	RunCmd.Flags().StringVar(&runChannelArg, "channel", "", "download channel(release, rc, nightly, v8-canary or test)")
 	RunCmd.Flags().BoolVarP(&runVerboseArg, "verbose", "v", false, "output verbose")
 }
 
@@ -58,72 +57,11 @@ const autoVersion = "auto"
 var ErrNotFoundGlobalVersion = fmt.Errorf("not found global version")
 
 func decideVersion(ctx context.Context, baseDir string) (string, error) {
-	globalVersion := func() (string, error) {
-		v, err := os.ReadFile(filepath.Join(baseDir, globalVersionFile))
-		if err != nil {
-			if os.IsNotExist(err) {
-				return "", ErrNotFoundGlobalVersion
-			}
-			return "", err
-		}
-		return string(v), nil
-	}
-
-	var (
-		priority       int
-		primaryVersion string
-	)
-	for dir := "."; ; dir = filepath.Join("..", dir) {
-		directory, err := filepath.Abs(dir)
-		if err != nil {
-			debugf(ctx, "get %s abs: %v", dir, err)
-			return globalVersion()
-		}
-
-		if nodeVersionFile, err := os.Open(filepath.Join(directory, ".node-version")); err == nil {
-			debugf(ctx, "use .node-version")
-			b, err := io.ReadAll(nodeVersionFile)
-			if err != nil {
-				return "", err
-			}
-			v := strings.TrimRight(string(b), "\n")
-			if priority > 0 {
-				curPriority := calcPriority(strings.Split(v, "."))
-				if curPriority > priority {
-					return v, nil
-				}
-				continue
-			}
-			return strings.TrimRight(string(b), "\n"), nil
-		}
-		if packageFile, err := os.Open(filepath.Join(directory, "package.json")); err == nil {
-			debugf(ctx, "parse package.json")
-			var packageJson struct {
-				Engines struct {
-					Node string `json:"node"`
-				} `json:"engines"`
-			}
-			if err := json.NewDecoder(packageFile).Decode(&packageJson); err != nil {
-				continue
-			}
-			if node := packageJson.Engines.Node; node != "" && !strings.ContainsAny(node, "|<>") {
-				return node, nil
-			}
-		}
-		if directory == "/" {
-			v, err := globalVersion()
-			if err != nil {
-				return "", err
-			}
-			if priority > 0 {
-				curPriority := calcPriority(strings.Split(v, "."))
-				if curPriority < priority {
-					return primaryVersion, nil
-				}
-			}
-			return v, nil
-		}
+	res, err := resolveVersion(ctx, baseDir, ".")
+	if err != nil {
+		return "", err
 	}
+	return res.used.Value, nil
 }
 
 func Run(ctx context.Context, versionStr string, command string, args []string) error {
@@ -132,9 +70,9 @@ func Run(ctx context.Context, versionStr string, command string, args []string)
 		return err
 	}
 
-	var parsedVersion *version
+	var parsedVersion *versionSpec
 	if versionStr != autoVersion {
-		parsedVersion, err = parseVersionString(versionStr)
+		parsedVersion, err = resolveVersionString(ctx, versionStr)
 		if err != nil {
 			return err
 		}
@@ -143,16 +81,22 @@ func Run(ctx context.Context, versionStr string, command string, args []string)
 		if err != nil {
 			return err
 		}
-		parsedVersion, err = parseVersionString(versionStr)
+		parsedVersion, err = resolveVersionString(ctx, versionStr)
 		if err != nil {
 			return err
 		}
 	}
+	parsedVersion, err = parsedVersion.withChannel(runChannelArg)
+	if err != nil {
+		return err
+	}
 	nodeBasePath, err := findLocalVersion(baseDir, parsedVersion)
 	if err != nil {
 		if errors.Is(err, ErrNotFoundLocalVersion) {
-			warnf(ctx, "download %s version", versionStr)
-			if err := Download(ctx, parsedVersion); err != nil {
+			if !offline {
+				warnf(ctx, "download %s version", versionStr)
+			}
+			if err := Download(ctx, parsedVersion, hostPlatform()); err != nil {
 				return err
 			}
 			nodeBasePath, err = findLocalVersion(baseDir, parsedVersion)
@@ -182,37 +126,32 @@ func Run(ctx context.Context, versionStr string, command string, args []string)
 	return nil
 }
 
-type localFile struct {
-	name     string
-	priority int
-}
-
 var ErrNotFoundLocalVersion = fmt.Errorf("not found local nodejs")
 
-func findLocalVersion(baseDir string, v *version) (string, error) {
+func findLocalVersion(baseDir string, spec *versionSpec) (string, error) {
 	files, err := os.ReadDir(filepath.Join(baseDir, "versions"))
 	if err != nil {
 		return "", err
 	}
-	var matchFiles []localFile
+	var (
+		found  bool
+		latest Version
+		name   string
+	)
 	for _, file := range files {
-		splitName := strings.Split(strings.TrimLeft(filepath.Base(file.Name()), "v"), ".")
-		match, err := compareVersionString(splitName, v)
+		v, err := parseVersion(filepath.Base(file.Name()))
 		if err != nil {
 			return "", err
 		}
-		if match {
-			matchFiles = append(matchFiles, localFile{
-				name:     file.Name(),
-				priority: calcPriority(splitName),
-			})
+		if !isHostInstall(filepath.Join(baseDir, "versions", file.Name())) {
+			continue
+		}
+		if spec.match(v) && (!found || v.Compare(latest) > 0) {
+			found, latest, name = true, v, file.Name()
 		}
 	}
-	if len(matchFiles) == 0 {
+	if !found {
 		return "", ErrNotFoundLocalVersion
 	}
-	slices.SortFunc(matchFiles, func(l, r localFile) int {
-		return r.priority - l.priority
-	})
-	return matchFiles[0].name, nil
+	return name, nil
 }
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFindLocalVersion(t *testing.T) {
+	base := t.TempDir()
+	writeFile(t, filepath.Join(base, "versions", "v20.1.0", "bin", "node"), "")
+	writeFile(t, filepath.Join(base, "versions", "v20.2.0", "bin", "node"), "")
+	// installed for another platform by an old nvs.
+	other := platform{os: "aix", arch: "ppc64"}
+	if err := writeInstallReceipt(filepath.Join(base, "versions", "v20.2.0"), installReceipt{Version: "v20.2.0", Platform: other.String()}); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(base, "versions", "v18.0.0", "bin", "node"), "")
+	if err := writeInstallReceipt(filepath.Join(base, "versions", "v18.0.0"), installReceipt{Version: "v18.0.0", Platform: hostPlatform().String()}); err != nil {
+		t.Fatal(err)
+	}
+
+	for rng, want := range map[string]string{"20": "v20.1.0", "18": "v18.0.0"} {
+		spec, err := parseVersionString(rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := findLocalVersion(base, spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("findLocalVersion(%s) = %s, want %s", rng, got, want)
+		}
+	}
+	if got := installPath(base, "v20.2.0", other); got != filepath.Join(base, platformsDir, "aix-ppc64", "v20.2.0") {
+		t.Errorf("installPath = %s", got)
+	}
+}
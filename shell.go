@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var shellUnsetArg bool
+
+var ShellCmd = &cobra.Command{
+	Use:   "shell [version]",
+	Short: "Print the command to select Nodejs version in the current shell",
+	Long: `Print the command to select Nodejs version in the current shell.
+
+  eval "$(nvs shell 20)"
+  eval "$(nvs shell --unset)"`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if shellUnsetArg == (len(args) == 1) {
+			cmd.Usage()
+			os.Exit(1)
+		}
+		var versionStr string
+		if len(args) == 1 {
+			versionStr = args[0]
+		}
+		script, err := Shell(versionStr)
+		if err != nil {
+			fatal(cmd.Context(), err)
+		}
+		fmt.Println(script)
+	},
+}
+
+func init() {
+	ShellCmd.Flags().BoolVar(&shellUnsetArg, "unset", false, "unset the version of the current shell")
+}
+
+func Shell(versionStr string) (string, error) {
+	fish := filepath.Base(os.Getenv("SHELL")) == "fish"
+	if versionStr == "" {
+		if fish {
+			return "set -e " + versionEnv, nil
+		}
+		return "unset " + versionEnv, nil
+	}
+	if _, err := parseVersionString(versionStr); err != nil {
+		return "", err
+	}
+	if fish {
+		quoted := strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(versionStr)
+		return fmt.Sprintf("set -gx %s '%s'", versionEnv, quoted), nil
+	}
+	quoted := strings.ReplaceAll(versionStr, "'", `'\''`)
+	return fmt.Sprintf("export %s='%s'", versionEnv, quoted), nil
+}
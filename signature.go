@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/spf13/cobra"
+)
+
+//go:generate go run . keys refresh --output release-keys.asc
+
+//go:embed release-keys.asc
+var bundledReleaseKeys []byte
+
+const (
+	releaseKeysFile = "release-keys.asc"
+	releaseKeysURL  = "https://raw.githubusercontent.com/nodejs/release-keys/HEAD/"
+)
+
+var insecureSkipVerify bool
+
+// signedChannels publish signed SHASUMS256.txt.
+var signedChannels = []string{releaseChannel, "rc"}
+
+var (
+	ErrNoReleaseKeys    = fmt.Errorf("no release keys. Run `nvs keys refresh`")
+	ErrInvalidSignature = fmt.Errorf("invalid signature")
+)
+
+var keysOutputArg string
+
+var KeysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage Node.js release keys",
+}
+
+var keysRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Fetch Node.js release keys from github.com/nodejs/release-keys",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+		keys, err := fetchReleaseKeys(ctx)
+		if err != nil {
+			fatal(ctx, err)
+		}
+		if err := saveReleaseKeys(ctx, keys, keysOutputArg); err != nil {
+			fatal(ctx, err)
+		}
+	},
+}
+
+var keysImportCmd = &cobra.Command{
+	Use:   "import [file...]",
+	Short: "Replace release keys with armored key files",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		var keys openpgp.EntityList
+		for _, arg := range args {
+			b, err := os.ReadFile(arg)
+			if err != nil {
+				fatal(ctx, err)
+			}
+			el, err := readArmoredKeys(b)
+			if err != nil {
+				fatal(ctx, fmt.Errorf("read %s: %w", arg, err))
+			}
+			keys = append(keys, el...)
+		}
+		if err := saveReleaseKeys(ctx, keys, keysOutputArg); err != nil {
+			fatal(ctx, err)
+		}
+	},
+}
+
+var keysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List release keys",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+		baseDir, err := checkInit()
+		if err != nil {
+			fatal(ctx, err)
+		}
+		keys, err := loadReleaseKeys(baseDir)
+		if err != nil {
+			fatal(ctx, err)
+		}
+		var buf strings.Builder
+		for _, key := range keys {
+			var name string
+			if identity := key.PrimaryIdentity(); identity != nil {
+				name = identity.Name
+			}
+			fmt.Fprintf(&buf, "%X %s\n", key.PrimaryKey.Fingerprint, name)
+		}
+		os.Stdout.WriteString(buf.String())
+	},
+}
+
+var keysResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Use the release keys bundled in nvs",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		ctx := cmd.Context()
+		baseDir, err := checkInit()
+		if err != nil {
+			fatal(ctx, err)
+		}
+		if err := os.Remove(filepath.Join(baseDir, releaseKeysFile)); err != nil && !os.IsNotExist(err) {
+			fatal(ctx, err)
+		}
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{keysRefreshCmd, keysImportCmd} {
+		cmd.Flags().StringVar(&keysOutputArg, "output", "", "output file (default $HOME/.nvs/"+releaseKeysFile+")")
+	}
+	KeysCmd.AddCommand(keysRefreshCmd, keysImportCmd, keysListCmd, keysResetCmd)
+}
+
+// loadReleaseKeys loads $HOME/.nvs/release-keys.asc if it exists. Otherwise, the bundled keys are used.
+func loadReleaseKeys(baseDir string) (openpgp.EntityList, error) {
+	b, err := os.ReadFile(filepath.Join(baseDir, releaseKeysFile))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		b = bundledReleaseKeys
+	}
+	keys, err := readArmoredKeys(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoReleaseKeys
+	}
+	return keys, nil
+}
+
+// readArmoredKeys reads concatenated armored public key blocks.
+func readArmoredKeys(b []byte) (openpgp.EntityList, error) {
+	const begin = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+	var keys openpgp.EntityList
+	for _, block := range strings.SplitAfter(string(b), "-----END PGP PUBLIC KEY BLOCK-----") {
+		i := strings.Index(block, begin)
+		if i < 0 {
+			continue
+		}
+		el, err := openpgp.ReadArmoredKeyRing(strings.NewReader(block[i:]))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, el...)
+	}
+	return keys, nil
+}
+
+func saveReleaseKeys(ctx context.Context, keys openpgp.EntityList, output string) error {
+	if output == "" {
+		baseDir, err := checkInit()
+		if err != nil {
+			return err
+		}
+		output = filepath.Join(baseDir, releaseKeysFile)
+	}
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := key.Serialize(w); err != nil {
+			return fmt.Errorf("serialize %X: %w", key.PrimaryKey.Fingerprint, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	if err := os.WriteFile(output, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	infof(ctx, "save %d keys to %s", len(keys), output)
+	return nil
+}
+
+func fetchReleaseKeys(ctx context.Context) (openpgp.EntityList, error) {
+	list, err := fetchURL(ctx, releaseKeysURL+"keys.list")
+	if err != nil {
+		return nil, err
+	}
+	var keys openpgp.EntityList
+	scanner := bufio.NewScanner(bytes.NewReader(list))
+	for scanner.Scan() {
+		fingerprint := strings.TrimSpace(scanner.Text())
+		if fingerprint == "" || strings.HasPrefix(fingerprint, "#") {
+			continue
+		}
+		debugf(ctx, "fetch key %s", fingerprint)
+		b, err := fetchURL(ctx, releaseKeysURL+"keys/"+fingerprint+".asc")
+		if err != nil {
+			return nil, err
+		}
+		el, err := readArmoredKeys(b)
+		if err != nil {
+			return nil, fmt.Errorf("read key %s: %w", fingerprint, err)
+		}
+		keys = append(keys, el...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoReleaseKeys
+	}
+	return keys, nil
+}
+
+func fetchURL(ctx context.Context, u string) ([]byte, error) {
+	var body []byte
+	err := retry(ctx, "GET "+u, func() error {
+		req, err := newRequest(ctx, http.MethodGet, u)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return requestError(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return responseError(resp)
+		}
+		body, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return &retryableError{err: err}
+		}
+		return nil
+	})
+	return body, err
+}
+
+// signatureConfig verifies the signature at the time it was created,
+// so that releases signed by keys which have expired since are still valid.
+func signatureConfig(sig []byte) *packet.Config {
+	p, err := packet.Read(bytes.NewReader(sig))
+	if err != nil {
+		return nil
+	}
+	s, ok := p.(*packet.Signature)
+	if !ok {
+		return nil
+	}
+	created := s.CreationTime
+	return &packet.Config{Time: func() time.Time { return created }}
+}
+
+// verifyChecksumsSignature verifies SHASUMS256.txt with SHASUMS256.txt.sig or SHASUMS256.txt.asc
+// and returns the verified content.
+func verifyChecksumsSignature(ctx context.Context, baseDir, channel, versionDir string, shasums []byte) ([]byte, error) {
+	if insecureSkipVerify {
+		warnf(ctx, "skip signature verification of %s", shasumsFile)
+		return shasums, nil
+	}
+
+	sig, sigErr := fetchReleaseFile(ctx, channel, versionDir, shasumsFile+".sig")
+	var asc []byte
+	if sigErr != nil {
+		var ascErr error
+		asc, ascErr = fetchReleaseFile(ctx, channel, versionDir, shasumsFile+".asc")
+		if ascErr != nil {
+			if errors.Is(sigErr, ErrNotFoundFile) && errors.Is(ascErr, ErrNotFoundFile) && !slices.Contains(signedChannels, channel) {
+				warnf(ctx, "%s channel does not publish signature of %s", channel, shasumsFile)
+				return shasums, nil
+			}
+			return nil, fmt.Errorf("fetch signature: %w", errors.Join(sigErr, ascErr))
+		}
+	}
+
+	keys, err := loadReleaseKeys(baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if sig != nil {
+		signer, err := openpgp.CheckDetachedSignature(keys, bytes.NewReader(shasums), bytes.NewReader(sig), signatureConfig(sig))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s of %s: %v", ErrInvalidSignature, shasumsFile, versionDir, err)
+		}
+		debugf(ctx, "%s is signed by %X", shasumsFile, signer.PrimaryKey.Fingerprint)
+		return shasums, nil
+	}
+
+	// the signature body can be read only once, so the block is decoded twice.
+	sigBlock, _ := clearsign.Decode(asc)
+	block, _ := clearsign.Decode(asc)
+	if block == nil {
+		return nil, fmt.Errorf("%w: %s.asc of %s is not clearsigned", ErrInvalidSignature, shasumsFile, versionDir)
+	}
+	sig, err = io.ReadAll(sigBlock.ArmoredSignature.Body)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := block.VerifySignature(keys, signatureConfig(sig))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s.asc of %s: %v", ErrInvalidSignature, shasumsFile, versionDir, err)
+	}
+	debugf(ctx, "%s is signed by %X", shasumsFile, signer.PrimaryKey.Fingerprint)
+	return block.Plaintext, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// stagingDir is where tarballs are extracted before installing.
+// It is in the nvs directory so that installing is a rename on the same filesystem.
+const stagingDir = "staging"
+
+// stagingOldDir keeps the replaced version until the new version is installed.
+const stagingOldDir = "old"
+
+// newStagingDir creates a staging directory owned by the current process.
+// The directory name starts with the pid to find leftovers of dead processes.
+func newStagingDir(base string) (string, error) {
+	dir := filepath.Join(base, stagingDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(dir, strconv.Itoa(os.Getpid())+"-*")
+}
+
+var errExchangeNotSupported = fmt.Errorf("exchange is not supported")
+
+// installDir moves from to target in base. The existing target is swapped with from in one step where exchangeDirs is supported,
+// and the old version is left at from. Otherwise, it is two renames: the existing target is moved into the staging directory first,
+// and it is restored if the new version cannot be moved. target is missing between them.
+func installDir(base, staging, from, target string) error {
+	// the parent such as platforms/linux-arm64 may not exist yet.
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	err := exchangeDirs(from, target)
+	if err == nil || !errors.Is(err, errExchangeNotSupported) && !os.IsNotExist(err) {
+		return err
+	}
+
+	// the old version is kept at the same relative path to find where it is restored.
+	rel, err := filepath.Rel(base, target)
+	if err != nil {
+		return err
+	}
+	old := filepath.Join(staging, stagingOldDir, rel)
+	if err := os.MkdirAll(filepath.Dir(old), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(target, old); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(from, target); err != nil {
+		if restoreErr := os.Rename(old, target); restoreErr != nil && !os.IsNotExist(restoreErr) {
+			return errors.Join(err, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// cleanStaging removes staging directories left by dead processes.
+// A replaced version is restored if the process died before installing the new version.
+func cleanStaging(ctx context.Context) error {
+	base, err := checkInit()
+	if err != nil {
+		return nil
+	}
+	entries, err := os.ReadDir(filepath.Join(base, stagingDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		pid, _, _ := strings.Cut(entry.Name(), "-")
+		if n, err := strconv.Atoi(pid); err == nil && processAlive(n) {
+			continue
+		}
+		dir := filepath.Join(base, stagingDir, entry.Name())
+		oldDir := filepath.Join(dir, stagingOldDir)
+		for _, pattern := range []string{filepath.Join("versions", "*"), filepath.Join(platformsDir, "*", "*")} {
+			olds, err := filepath.Glob(filepath.Join(oldDir, pattern))
+			if err != nil {
+				return err
+			}
+			for _, old := range olds {
+				rel, err := filepath.Rel(oldDir, old)
+				if err != nil {
+					return err
+				}
+				if err := restoreVersion(ctx, base, old, filepath.Join(base, rel)); err != nil {
+					return err
+				}
+			}
+		}
+		debugf(ctx, "remove staging %s", dir)
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreVersion moves the replaced version back to target if no version is installed.
+// It takes the lock of target not to race with a live install.
+func restoreVersion(ctx context.Context, base, old, target string) error {
+	unlock, err := lockInstall(ctx, base, target)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if _, err := os.Lstat(target); !os.IsNotExist(err) {
+		return nil
+	}
+	warnf(ctx, "restore %s from interrupted install", target)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	return os.Rename(old, target)
+}
+
+func processAlive(pid int) bool {
+	if pid == os.Getpid() {
+		return true
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		// FindProcess opens the process on Windows, and signal 0 is not supported.
+		p.Release()
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// exchangeDirs swaps from and target in one step with renameat2(RENAME_EXCHANGE).
+func exchangeDirs(from, target string) error {
+	err := unix.Renameat2(unix.AT_FDCWD, from, unix.AT_FDCWD, target, unix.RENAME_EXCHANGE)
+	if errors.Is(err, unix.EINVAL) || errors.Is(err, unix.ENOSYS) {
+		// the filesystem or the kernel does not support RENAME_EXCHANGE.
+		return errExchangeNotSupported
+	}
+	if err != nil {
+		return &os.LinkError{Op: "exchange", Old: from, New: target, Err: err}
+	}
+	return nil
+}
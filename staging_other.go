@@ -0,0 +1,7 @@
+//go:build !linux
+
+package main
+
+func exchangeDirs(from, target string) error {
+	return errExchangeNotSupported
+}
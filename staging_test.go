@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInstallDir(t *testing.T) {
+	for _, exists := range []bool{false, true} {
+		base := t.TempDir()
+		staging, err := newStagingDir(base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		target := filepath.Join(base, "versions", "v20.0.0")
+		if exists {
+			writeFile(t, filepath.Join(target, "old"), "old")
+		} else if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		from := filepath.Join(staging, "node-v20.0.0-linux-x64")
+		writeFile(t, filepath.Join(from, "new"), "new")
+
+		if err := installDir(base, staging, from, target); err != nil {
+			t.Fatalf("exists=%v: %v", exists, err)
+		}
+		if _, err := os.Stat(filepath.Join(target, "new")); err != nil {
+			t.Errorf("exists=%v: %v", exists, err)
+		}
+		if _, err := os.Stat(filepath.Join(target, "old")); !os.IsNotExist(err) {
+			t.Errorf("exists=%v: old version is left in target: %v", exists, err)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCleanStaging(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	base := filepath.Join(home, nvsDir)
+	// the staging directory of a dead process which was between the two renames of installDir.
+	dead := filepath.Join(base, stagingDir, "999999999-1")
+	writeFile(t, filepath.Join(dead, stagingOldDir, "versions", "v20.0.0", "old"), "old")
+	writeFile(t, filepath.Join(dead, stagingOldDir, "versions", "v18.0.0", "old"), "old")
+	writeFile(t, filepath.Join(dead, stagingOldDir, platformsDir, "linux-arm64", "v20.0.0", "old"), "old")
+	writeFile(t, filepath.Join(base, "versions", "v18.0.0", "new"), "new")
+	live, err := newStagingDir(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cleanStaging(testContext()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "versions", "v20.0.0", "old")); err != nil {
+		t.Errorf("v20.0.0 is not restored: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, platformsDir, "linux-arm64", "v20.0.0", "old")); err != nil {
+		t.Errorf("linux-arm64 v20.0.0 is not restored: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "versions", "v18.0.0", "new")); err != nil {
+		t.Errorf("installed v18.0.0 is replaced: %v", err)
+	}
+	if _, err := os.Stat(dead); !os.IsNotExist(err) {
+		t.Errorf("staging of the dead process is left: %v", err)
+	}
+	if _, err := os.Stat(live); err != nil {
+		t.Errorf("staging of this process is removed: %v", err)
+	}
+}
@@ -47,7 +47,13 @@ func Use(_ context.Context, versionStr string) error {
 		}
 		versionFile = globalVersionFile
 	}
-	if err := os.WriteFile(filepath.Join(baseDir, versionFile), []byte(strings.TrimLeft(versionStr, "v")), 0644); err != nil {
+	if _, err := parseVersionString(versionStr); err != nil {
+		return err
+	}
+	if !isChannel(versionStr) {
+		versionStr = strings.TrimLeft(versionStr, "v")
+	}
+	if err := os.WriteFile(filepath.Join(baseDir, versionFile), []byte(versionStr), 0644); err != nil {
 		return err
 	}
 	return nil
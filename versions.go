@@ -4,18 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
+	"slices"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
-	"golang.org/x/net/html"
 )
 
-var versionsRemoteArg bool
+var (
+	versionsRemoteArg  bool
+	versionsChannelArg string
+)
 
 var VersionsCmd = &cobra.Command{
 	Use:   "versions",
@@ -24,7 +26,10 @@ var VersionsCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, _ []string) {
 		ctx := cmd.Context()
 		if versionsRemoteArg {
-			if err := outputRemoteVersions(); err != nil {
+			if !isChannel(versionsChannelArg) {
+				fatal(ctx, fmt.Errorf("unknown channel %s", versionsChannelArg))
+			}
+			if err := outputRemoteVersions(ctx); err != nil {
 				fatal(ctx, err)
 			}
 		} else {
@@ -37,52 +42,43 @@ var VersionsCmd = &cobra.Command{
 
 func init() {
 	VersionsCmd.Flags().BoolVar(&versionsRemoteArg, "remote", false, "list remote versions")
+	VersionsCmd.Flags().StringVar(&versionsChannelArg, "channel", releaseChannel, "remote channel(release, rc, nightly, v8-canary or test)")
 }
 
-var versionRegex = regexp.MustCompile(`^v[0-9]{1,2}\.[0-9]{1,2}\.[0-9]{1,2}/$`)
-
-func outputRemoteVersions() error {
-	r, err := http.NewRequest(http.MethodGet, nodejsURL, nil)
-	if err != nil {
-		return err
-	}
-	resp, err := http.DefaultClient.Do(r)
+func outputRemoteVersions(ctx context.Context) error {
+	releases, err := fetchReleaseIndex(ctx, versionsChannelArg)
 	if err != nil {
 		return err
 	}
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		return fmt.Errorf("status is %d. response %s", resp.StatusCode, body)
+	if offline {
+		if cache, err := readIndexCache(versionsChannelArg); err == nil {
+			warnf(ctx, "offline: the index was fetched %s ago", time.Since(cache.FetchedAt).Round(time.Second))
+		}
 	}
 
-	doc, err := html.Parse(resp.Body)
-	if err != nil {
-		return err
-	}
-	var f func(*html.Node) error
 	var buf strings.Builder
-	f = func(n *html.Node) error {
-		if n.Type == html.ElementNode && n.Data == "a" {
-			data := n.FirstChild.Data
-			if !versionRegex.MatchString(data) {
-				return nil
-			}
-			buf.WriteString(strings.TrimRight(data, "/"))
-			buf.WriteRune('\n')
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for i := len(releases) - 1; i >= 0; i-- {
+		release := releases[i]
+		var lts, security string
+		if release.LTS != "" {
+			lts = ltsPrefix + strings.ToLower(release.LTS)
 		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			if err := f(c); err != nil {
-				return err
-			}
+		if release.Security {
+			security = "security"
 		}
-		return nil
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", release.Version, release.Date, release.Npm, lts, security)
 	}
-	if err := f(doc); err != nil {
+	if err := w.Flush(); err != nil {
 		return err
 	}
-
-	os.Stdout.WriteString(buf.String())
+	var out strings.Builder
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if line != "" {
+			out.WriteString(strings.TrimRight(line, " \n") + "\n")
+		}
+	}
+	os.Stdout.WriteString(out.String())
 
 	return nil
 }
@@ -92,23 +88,40 @@ func outputLocalVersions(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	versions, err := os.ReadDir(filepath.Join(baseDir, "versions"))
+	files, err := os.ReadDir(filepath.Join(baseDir, "versions"))
 	if err != nil {
 		return err
 	}
+	versions := make([]Version, 0, len(files))
+	for _, file := range files {
+		v, err := parseVersion(file.Name())
+		if err != nil {
+			return err
+		}
+		if !isHostInstall(filepath.Join(baseDir, "versions", file.Name())) {
+			debugf(ctx, "%s is not for this machine", file.Name())
+			continue
+		}
+		versions = append(versions, v)
+	}
+	slices.SortFunc(versions, Version.Compare)
 
 	getVersion := func(versionString string) (string, error) {
 		if versionString == "" {
 			return "", nil
 		}
-		parsedVersion, err := parseVersionString(versionString)
+		parsedVersion, err := resolveVersionString(ctx, versionString)
 		if err != nil {
 			return "", err
 		}
 		path, err := findLocalVersion(baseDir, parsedVersion)
 		if err != nil {
 			if errors.Is(err, ErrNotFoundLocalVersion) {
-				if err := Download(ctx, parsedVersion); err != nil {
+				if err := Download(ctx, parsedVersion, hostPlatform()); err != nil {
+					if errors.Is(err, ErrOffline) {
+						warnf(ctx, "%v", err)
+						return "", nil
+					}
 					return "", err
 				}
 				path, err = findLocalVersion(baseDir, parsedVersion)
@@ -150,7 +163,7 @@ func outputLocalVersions(ctx context.Context) error {
 
 	var buf strings.Builder
 	for _, version := range versions {
-		name := version.Name()
+		name := version.String()
 		switch {
 		case name == globalVersion && name == currentVersion:
 			buf.WriteRune('*')